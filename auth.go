@@ -0,0 +1,201 @@
+package panggilhttp
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/KodepandaID/panggilhttp/pkg/oauth2"
+	"github.com/valyala/fasthttp"
+)
+
+// APIKeyLocation is where the API key is sent.
+type APIKeyLocation int
+
+const (
+	// APIKeyInHeader to send the API key as a header.
+	APIKeyInHeader APIKeyLocation = iota
+	// APIKeyInQuery to send the API key as a query parameter.
+	APIKeyInQuery
+)
+
+// TokenSource to get a token for every HTTP attempt, it must be safe for concurrent use.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc is a function to get a token.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token to call the function.
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Signer signs the request, like HTTP Message Signatures with the signature package.
+// The request is signed for every HTTP attempt after the body and the credentials are set.
+type Signer interface {
+	Sign(req *fasthttp.Request) error
+}
+
+// TokenInvalidator is a TokenSource which can drop a rejected token.
+// If the response is 401 Unauthorized, the rejected token is invalidated
+// and the HTTP attempt is made once again with a new token.
+type TokenInvalidator interface {
+	InvalidateToken(token string)
+}
+
+// authFunc to set the credentials to the request.
+// The credentials are kept in the function, so they are never printed with the configuration.
+type authFunc func(ctx context.Context, req *fasthttp.Request) error
+
+// authenticator is a credentials of the HTTP call.
+type authenticator struct {
+	set    authFunc
+	source TokenSource // to invalidate the bearer token if it is rejected
+}
+
+func basicAuth(username, password string) authenticator {
+	credentials := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+
+	return authenticator{
+		set: func(ctx context.Context, req *fasthttp.Request) error {
+			req.Header.Set(fasthttp.HeaderAuthorization, credentials)
+
+			return nil
+		},
+	}
+}
+
+func bearerAuth(ts TokenSource) authenticator {
+	return authenticator{
+		set: func(ctx context.Context, req *fasthttp.Request) error {
+			token, e := ts.Token(ctx)
+			if e != nil {
+				return e
+			}
+
+			req.Header.Set(fasthttp.HeaderAuthorization, "Bearer "+token)
+
+			return nil
+		},
+		source: ts,
+	}
+}
+
+func apiKeyAuth(name, value string, in APIKeyLocation) authenticator {
+	return authenticator{
+		set: func(ctx context.Context, req *fasthttp.Request) error {
+			if in == APIKeyInQuery {
+				req.URI().QueryArgs().Set(name, value)
+			} else {
+				req.Header.Set(name, value)
+			}
+
+			return nil
+		},
+	}
+}
+
+// invalidateToken to invalidate the rejected bearer token of the request.
+// It returns true if one of the token sources can give a new token.
+func invalidateToken(auths []authenticator, req *fasthttp.Request) bool {
+	token := strings.TrimPrefix(string(req.Header.Peek(fasthttp.HeaderAuthorization)), "Bearer ")
+
+	invalidated := false
+	for _, auth := range auths {
+		if invalidator, ok := auth.source.(TokenInvalidator); ok {
+			invalidator.InvalidateToken(token)
+			invalidated = true
+		}
+	}
+
+	return invalidated
+}
+
+// WithBasicAuth to send the HTTP basic authentication for every HTTP call.
+func (cl *Client) WithBasicAuth(username, password string) *Client {
+	cl.auths = append(cl.auths, basicAuth(username, password))
+
+	return cl
+}
+
+// WithBearerToken to send the bearer token for every HTTP call.
+func (cl *Client) WithBearerToken(token string) *Client {
+	return cl.WithTokenSource(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return token, nil
+	}))
+}
+
+// WithTokenSource to send the bearer token from the token source for every HTTP call.
+// If the token source is a TokenInvalidator, the rejected token is refreshed once.
+func (cl *Client) WithTokenSource(ts TokenSource) *Client {
+	cl.auths = append(cl.auths, bearerAuth(ts))
+
+	return cl
+}
+
+// WithOAuth2 to send the bearer token from the OAuth2 client credentials flow for every HTTP call.
+// The token is cached until shortly before it is expired,
+// and it is refreshed once if the response is 401 Unauthorized.
+func (cl *Client) WithOAuth2(cfg *oauth2.Config) *Client {
+	return cl.WithTokenSource(oauth2.New(cfg))
+}
+
+// WithAPIKey to send the API key as a header or a query parameter for every HTTP call.
+func (cl *Client) WithAPIKey(name, value string, in APIKeyLocation) *Client {
+	cl.auths = append(cl.auths, apiKeyAuth(name, value, in))
+
+	return cl
+}
+
+// WithSigner to sign every HTTP call.
+func (cl *Client) WithSigner(signer Signer) *Client {
+	cl.signer = signer
+
+	return cl
+}
+
+// WithSigner to sign the HTTP call, like signature.New for HTTP Message Signatures.
+// The request is signed after the body and the credentials are set.
+func (c *Config) WithSigner(signer Signer) *Config {
+	c.signer = signer
+	c.callCredentials = true
+
+	return c
+}
+
+// WithBasicAuth to send the HTTP basic authentication.
+// The credentials are set after the middlewares, so the middlewares never see them.
+func (c *Config) WithBasicAuth(username, password string) *Config {
+	c.auths = append(c.auths, basicAuth(username, password))
+	c.callCredentials = true
+
+	return c
+}
+
+// WithBearerToken to send the bearer token.
+// The token is set after the middlewares, so the middlewares never see it.
+func (c *Config) WithBearerToken(token string) *Config {
+	return c.WithTokenSource(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return token, nil
+	}))
+}
+
+// WithTokenSource to send the bearer token from the token source.
+// The token is fetched for every HTTP attempt, so it is never stored in the configuration.
+func (c *Config) WithTokenSource(ts TokenSource) *Config {
+	c.auths = append(c.auths, bearerAuth(ts))
+	c.callCredentials = true
+
+	return c
+}
+
+// WithAPIKey to send the API key as a header or a query parameter.
+// The API key is set after the middlewares, so the middlewares never see it.
+func (c *Config) WithAPIKey(name, value string, in APIKeyLocation) *Config {
+	c.auths = append(c.auths, apiKeyAuth(name, value, in))
+	c.callCredentials = true
+
+	return c
+}
@@ -0,0 +1,189 @@
+package panggilhttp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp/pkg/cache"
+	"github.com/valyala/fasthttp"
+)
+
+// CacheStore stores the cached responses, like cache.NewLRU or cache.NewDisk.
+type CacheStore = cache.Store
+
+// CacheStatus is how the response is served by the cache.
+type CacheStatus string
+
+const (
+	// CacheMiss is the response from the HTTP call, it is stored if it is storable.
+	CacheMiss CacheStatus = "miss"
+	// CacheHit is the fresh stored response, the HTTP is not called.
+	CacheHit CacheStatus = "hit"
+	// CacheRevalidated is the stored response after the HTTP responds with 304 Not Modified.
+	CacheRevalidated CacheStatus = "revalidated"
+	// CacheStale is the stale stored response, served while it is revalidated in the background
+	// by stale-while-revalidate, or because the HTTP call fails by stale-if-error.
+	CacheStale CacheStatus = "stale"
+)
+
+// WithCache to cache the responses of the GET method by RFC 9111,
+// the Cache-Control, Expires and Vary headers are respected.
+// The stored response which is not fresh is revalidated with If-None-Match
+// and If-Modified-Since, a 304 response is served from the stored response.
+// The response of a POST, PUT, PATCH or DELETE method invalidates the stored response of the URL.
+// By stale-while-revalidate, the stale response is served while it is revalidated in the background,
+// and by stale-if-error, the stale response is served if the HTTP call fails after every retry.
+// The stored response is only served for the same credentials of the HTTP call,
+// the response for other credentials replaces the stored response of the URL.
+// The signed HTTP call, or the HTTP call with the API key, is not cached.
+func (cl *Client) WithCache(cfg *cache.Config) *Client {
+	cl.cache = cache.New(cfg)
+
+	return cl
+}
+
+// callCache to serve the fresh stored response without calling the HTTP,
+// or the stale stored response by stale-while-revalidate and stale-if-error,
+// otherwise the HTTP is called and the stored response is revalidated.
+// The cache request is the request with the credentials, to get the stored response of the credentials.
+func (c *Config) callCache(ctx context.Context, row urlConfig, req, cacheReq *fasthttp.Request, resp *fasthttp.Response) SourceResult {
+	stored, fresh := c.client.cache.Lookup(cacheReq, time.Now())
+	if stored == nil {
+		return c.fetch(ctx, row, req, resp, cacheReq, nil)
+	}
+
+	if fresh {
+		return c.serveStored(row, stored, resp, CacheHit)
+	}
+
+	if c.client.cache.StaleWhileRevalidate(stored, time.Now()) {
+		if c.client.cache.BeginRevalidate(stored.Key) {
+			bgReq := fasthttp.AcquireRequest()
+			req.CopyTo(bgReq)
+			bgCacheReq := fasthttp.AcquireRequest()
+			cacheReq.CopyTo(bgCacheReq)
+
+			go c.snapshot().revalidate(row, bgReq, bgCacheReq, stored)
+		}
+
+		return c.serveStored(row, stored, resp, CacheStale)
+	}
+
+	result := c.fetch(ctx, row, req, resp, cacheReq, stored)
+
+	// The HTTP call is failed after every retry, or the server is failing.
+	failed := result.Err != nil && ctx.Err() == nil || result.StatusCode >= http.StatusInternalServerError
+	if failed && c.client.cache.StaleIfError(cacheReq, stored, time.Now()) {
+		stale := c.serveStored(row, stored, resp, CacheStale)
+		stale.Duration = result.Duration
+		stale.Attempts = result.Attempts
+
+		return stale
+	}
+
+	return result
+}
+
+// revalidate to refresh the stale stored response in the background, the requests are released after.
+func (c *Config) revalidate(row urlConfig, req, cacheReq *fasthttp.Request, stored *cache.Entry) {
+	defer c.client.cache.EndRevalidate(stored.Key)
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseRequest(cacheReq)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	c.fetch(context.Background(), row, req, resp, cacheReq, stored)
+}
+
+// snapshot to copy the configuration of the background revalidation,
+// so the HTTP call configuration can be changed and reused after DoContext returns.
+func (c *Config) snapshot() *Config {
+	cp := &Config{
+		client:         c.client,
+		timeout:        c.timeout,
+		retryPolicy:    c.retryPolicy,
+		retryPolicySet: c.retryPolicySet,
+		idempotencyKey: c.idempotencyKey,
+		auths:          append([]authenticator(nil), c.auths...),
+		signer:         c.signer,
+		maxRedirects:   c.maxRedirects,
+		checkRedirect:  c.checkRedirect,
+	}
+	cp.retryPolicy.StatusCodes = append([]int(nil), c.retryPolicy.StatusCodes...)
+	c.header.CopyTo(&cp.header)
+
+	return cp
+}
+
+// cacheRequest to copy the request with the credentials of the HTTP call,
+// the stored response is looked up and stored by the credentials.
+// It returns false if the HTTP call cannot be cached, like the request is signed,
+// or the credentials are sent in the query or in another header than the Authorization header.
+func (c *Config) cacheRequest(ctx context.Context, req *fasthttp.Request) (*fasthttp.Request, bool) {
+	if c.signer != nil {
+		return nil, false
+	}
+
+	cacheReq := fasthttp.AcquireRequest()
+	req.CopyTo(cacheReq)
+
+	for _, auth := range c.auths {
+		if e := auth.set(ctx, cacheReq); e != nil {
+			fasthttp.ReleaseRequest(cacheReq)
+
+			return nil, false
+		}
+	}
+
+	// The credentials in the query or in another header are never in the cache key.
+	cacheable := bytes.Equal(req.URI().FullURI(), cacheReq.URI().FullURI())
+	cacheReq.Header.VisitAll(func(key, value []byte) {
+		if !strings.EqualFold(string(key), fasthttp.HeaderAuthorization) && !bytes.Equal(req.Header.PeekBytes(key), value) {
+			cacheable = false
+		}
+	})
+	if !cacheable {
+		fasthttp.ReleaseRequest(cacheReq)
+
+		return nil, false
+	}
+
+	return cacheReq, true
+}
+
+// serveStored to create the result of the stored response.
+func (c *Config) serveStored(row urlConfig, stored *cache.Entry, resp *fasthttp.Response, status CacheStatus) SourceResult {
+	stored.WriteTo(resp, time.Now())
+
+	result := SourceResult{URL: row.url, Method: row.method, Cache: status}
+	setResponse(&result, resp, c.client.hc.MaxResponseBodySize)
+
+	return result
+}
+
+// cacheResponse to store the response, or to serve the stored response if it is not modified.
+// The response after the redirects is not stored for the original URL.
+func (c *Config) cacheResponse(req *fasthttp.Request, resp *fasthttp.Response, stored *cache.Entry, requestTime time.Time, redirected bool) CacheStatus {
+	now := time.Now()
+
+	if stored != nil && resp.StatusCode() == http.StatusNotModified {
+		stored = c.client.cache.Revalidated(stored, resp, requestTime, now)
+		stored.WriteTo(resp, now)
+
+		return CacheRevalidated
+	}
+
+	if !redirected {
+		c.client.cache.Store(req, resp, requestTime, now)
+	}
+
+	if !req.Header.IsGet() {
+		return ""
+	}
+
+	return CacheMiss
+}
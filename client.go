@@ -2,32 +2,127 @@ package panggilhttp
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
 	"mime/multipart"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/KodepandaID/panggilhttp/pkg/breaker"
+	"github.com/KodepandaID/panggilhttp/pkg/cache"
+	"github.com/KodepandaID/panggilhttp/pkg/cookiejar"
 	"github.com/KodepandaID/panggilhttp/pkg/merging"
+	"github.com/KodepandaID/panggilhttp/pkg/proxy"
+	"github.com/KodepandaID/panggilhttp/pkg/ratelimit"
 	"github.com/KodepandaID/panggilhttp/pkg/retry"
+	"github.com/KodepandaID/panggilhttp/pkg/singleflight"
 	"github.com/valyala/fasthttp"
 )
 
 var version = "panggilHTTP-v0.1.0"
 
+// Client is a reusable HTTP client, it keeps the connection pool between HTTP calls.
+// Client is safe for concurrent use after it is configured,
+// use R to create a new HTTP call configuration.
+type Client struct {
+	hc *fasthttp.Client // the configuration of the clients by the scheme
+
+	// Dial configuration, the clients by the scheme and the connection statistics
+	proxy       *proxy.Dialer
+	proxyEnv    *proxy.Environment
+	dialTimeout time.Duration
+	clientsMu   sync.Mutex
+	clients     map[string]*fasthttp.Client
+	tlsConfigs  map[string]*tls.Config
+	stats       poolStats
+
+	// Default configuration for every HTTP call
+	header         fasthttp.RequestHeader
+	timeout        time.Duration
+	concurrency    int
+	retryPolicy    retry.Policy
+	retryPolicySet bool // the retry policy is set, otherwise the HTTP call is retried once
+
+	// Circuit breaker and rate limit by the host, shared by every HTTP call
+	breakers *breaker.Group
+	limiters *ratelimit.Group
+
+	// Middlewares wrap every HTTP attempt
+	middlewares []Middleware
+
+	// Cookie jar to store and send the cookies between HTTP calls
+	jar *cookiejar.Jar
+
+	// Pinned public keys by the host
+	pins map[string][]string
+
+	// HTTP cache for the GET method, shared by every HTTP call
+	cache *cache.Cache
+
+	// Metrics collector of every HTTP call
+	metrics MetricsCollector
+
+	// In-flight GET calls shared by the concurrent identical HTTP calls
+	flights         *singleflight.Group
+	coalesceHeaders []string
+
+	// Default redirect configuration for every HTTP call
+	maxRedirects  int
+	checkRedirect CheckRedirectFunc
+
+	// Request body compression for every HTTP call
+	compression        string
+	compressionMinSize int
+
+	// Credentials and signer for every HTTP call
+	auths  []authenticator
+	signer Signer
+
+	// Configuration errors, returned by every HTTP call
+	errs []error
+}
+
 // Config to set the configuration to calling an HTTP.
+// Config is created per HTTP call and is not safe for concurrent use.
 type Config struct {
 	// HTTP configuration
-	client  *fasthttp.Client
-	req     *fasthttp.Request
+	client  *Client
+	header  fasthttp.RequestHeader
 	url     []urlConfig
 	timeout time.Duration // in Seconds
 
+	// Max HTTP calls running at the same time if calling more than one URL
+	concurrency int
+
 	// HTTP request body
-	body   bytes.Buffer
-	writer *multipart.Writer
+	body     []byte
+	jsonBody bool // the request body is set by SendJSON, so it can be compressed
+	form     bytes.Buffer
+	writer   *multipart.Writer
+
+	// HTTP request body compression
+	compression        string
+	compressionMinSize int
 
 	// HTTP retry configuration
-	retryInterval time.Duration // in Miliseconds
-	retryAttempt  int           // How much retry to calling an HTTP
+	retryPolicy    retry.Policy
+	retryPolicySet bool // the retry policy is set, otherwise the HTTP call is retried once
+	idempotencyKey bool // to retry the non-idempotent method with an Idempotency-Key header
+	callPolicy     bool // the retry policy or the redirect check is set to the HTTP call, so it is never coalesced
+
+	// HTTP authentication and signer, set to every HTTP attempt
+	auths           []authenticator
+	signer          Signer
+	callCredentials bool // the credentials or the signer is set to the HTTP call, so it is never coalesced
+
+	// HTTP redirect configuration
+	maxRedirects  int
+	checkRedirect CheckRedirectFunc
+
+	// Configuration errors, returned by Do before calling an HTTP
+	errs []error
 }
 
 type urlConfig struct {
@@ -43,91 +138,482 @@ type Response struct {
 	Headers    map[string]string
 	Cookies    map[string]string
 	Body       []byte
+
+	// Redirects is the followed redirects if only calling 1 URL.
+	Redirects []Redirect
+	// Cache is how the response is served by the cache if only calling 1 URL.
+	Cache CacheStatus
+
+	// Sources is the result of every URL in the declared order.
+	Sources []SourceResult
 }
 
-// New is an adapter to create new instance.
-func New() *Config {
-	req := fasthttp.AcquireRequest()
+// SourceResult is the result of calling one URL.
+// Body is the raw response body before merging.
+type SourceResult struct {
+	URL        string
+	Method     string
+	StatusCode int
+	Headers    map[string]string
+	Cookies    map[string]string
+	Body       []byte
+	Duration   time.Duration
+	Attempts   int
+	Redirects  []Redirect
+	Cache      CacheStatus
+	Coalesced  bool // the response is shared with the concurrent identical HTTP calls
+	Err        error
+}
 
-	return &Config{
-		client: &fasthttp.Client{
+// NewClient to create a new reusable client.
+func NewClient() *Client {
+	return &Client{
+		hc: &fasthttp.Client{
 			Name:                          version,
 			NoDefaultUserAgentHeader:      true,
 			ReadBufferSize:                4096,
 			WriteBufferSize:               4096,
 			DisableHeaderNamesNormalizing: true,
 		},
-		req: req,
+		clients:    make(map[string]*fasthttp.Client),
+		tlsConfigs: make(map[string]*tls.Config),
+		stats: poolStats{
+			open:     make(map[string]int),
+			inFlight: make(map[string]int),
+		},
+	}
+}
+
+// New is an adapter to create new instance.
+// It is a shortcut of NewClient().R(), use NewClient to reuse the connection pool.
+func New() *Config {
+	return NewClient().R()
+}
+
+// R to create a new HTTP call configuration with the client default configuration.
+func (cl *Client) R() *Config {
+	c := &Config{
+		client:         cl,
+		timeout:        cl.timeout,
+		concurrency:    cl.concurrency,
+		retryPolicy:    cl.retryPolicy,
+		retryPolicySet: cl.retryPolicySet,
+		auths:          append([]authenticator(nil), cl.auths...),
+		signer:         cl.signer,
+
+		compression:        cl.compression,
+		compressionMinSize: cl.compressionMinSize,
+
+		maxRedirects:  cl.maxRedirects,
+		checkRedirect: cl.checkRedirect,
+
+		errs: append([]error(nil), cl.errs...),
+	}
+	cl.header.CopyTo(&c.header)
+
+	return c
+}
+
+// WithHeader to set default HTTP headers for every HTTP call.
+func (cl *Client) WithHeader(headers map[string]string) *Client {
+	for key, val := range headers {
+		cl.header.Set(key, val)
+	}
+
+	return cl
+}
+
+// WithTimeout to set default HTTP timeout in seconds for every HTTP call.
+// The default value is 1 seconds.
+func (cl *Client) WithTimeout(second time.Duration) *Client {
+	if second < 1 {
+		cl.errs = append(cl.errs, ErrInvalidTimeout)
+
+		return cl
 	}
+
+	cl.timeout = time.Second * second
+
+	return cl
+}
+
+// WithConcurrency to set default max HTTP calls running at the same time,
+// if calling more than one URL.
+func (cl *Client) WithConcurrency(max int) *Client {
+	cl.concurrency = max
+
+	return cl
+}
+
+// WithCircuitBreaker to use a circuit breaker for every host.
+// If the circuit of the host is open, the HTTP call fails fast with a *CircuitOpenError.
+// A failed HTTP call or a 5xx status code is counted as a failure.
+func (cl *Client) WithCircuitBreaker(cfg *breaker.Config) *Client {
+	cl.breakers = breaker.NewGroup(cfg)
+
+	return cl
+}
+
+// WithRateLimit to limit the HTTP calls, globally or for every host.
+// Every request on the wire waits for a token, including the retries, the redirects
+// and the request with a refreshed token. The waiting is stopped if the context is done.
+// If FailFast is set, the HTTP call fails with a *RateLimitError instead of waiting.
+func (cl *Client) WithRateLimit(cfg *ratelimit.Config) *Client {
+	if cfg.Requests < 1 || cfg.Interval < 0 || cfg.Burst < 0 {
+		cl.errs = append(cl.errs, ErrInvalidRateLimit)
+
+		return cl
+	}
+
+	cl.limiters = ratelimit.NewGroup(cfg)
+
+	return cl
+}
+
+// WithRetryPolicy to set default HTTP retry policy for every HTTP call.
+func (cl *Client) WithRetryPolicy(policy retry.Policy) *Client {
+	if policy.Attempts < 0 || policy.MaxElapsedTime < 0 || policy.MaxRetryAfter < 0 {
+		cl.errs = append(cl.errs, ErrInvalidRetry)
+
+		return cl
+	}
+
+	cl.retryPolicy = policy
+	cl.retryPolicySet = true
+
+	return cl
+}
+
+// WithFailRetry to set default HTTP retry for every HTTP call.
+// interval args in miliseconds.
+// attempt args is int how much to retry HTTP calls.
+func (cl *Client) WithFailRetry(interval time.Duration, attempt int) *Client {
+	if interval < 1 || attempt < 1 {
+		cl.errs = append(cl.errs, ErrInvalidRetry)
+
+		return cl
+	}
+
+	cl.retryPolicy = retry.Policy{
+		Attempts: attempt,
+		Backoff:  retry.Constant{Interval: time.Millisecond * interval},
+	}
+	cl.retryPolicySet = true
+
+	return cl
 }
 
 // Do to running an HTTP call.
 // Response header and cookies can be returned if only use 1 call HTTP.
 func (c *Config) Do() (Response, error) {
-	var (
-		headers    map[string]string
-		cookies    map[string]string
-		statusCode int
-	)
+	return c.DoContext(context.Background())
+}
 
-	defer fasthttp.ReleaseRequest(c.req)
+// DoContext to running an HTTP call with a context.
+// If the context is canceled or the deadline is exceeded,
+// the in-flight HTTP call and the HTTP retry are stopped and a *CanceledError is returned.
+// If the configuration is invalid, a *BuilderError is returned before calling an HTTP.
+//
+// If calling more than one URL, the HTTP calls are running concurrently
+// and the response body is merged in the declared order.
+func (c *Config) DoContext(ctx context.Context) (Response, error) {
+	errs := c.errs
+	if len(c.url) == 0 {
+		errs = append(append([]error(nil), errs...), ErrNoURL)
+	}
+	if len(errs) > 0 {
+		return Response{}, &BuilderError{Errs: errs}
+	}
 
-	resp := fasthttp.AcquireResponse()
-	defer fasthttp.ReleaseResponse(resp)
+	// If the request body is a multipart/form-data,
+	// the writer will be closed.
+	if c.writer != nil {
+		c.writer.Close()
+
+		c.header.SetContentType(c.writer.FormDataContentType())
+		c.body = c.form.Bytes()
+		c.jsonBody = false
+		c.writer = nil
+	}
+	c.compressBody()
 
-	m := merging.New()
+	results := c.fanOut(ctx)
 
-	for _, row := range c.url {
-		c.req.SetRequestURI(row.url)
-		c.req.Header.SetMethod(row.method)
+	httpResponse := Response{
+		StatusCode: results[len(results)-1].StatusCode,
+		Sources:    results,
+	}
 
-		// If the request body is a multipart/form-data,
-		// the writer will be closed.
-		if c.body.Len() > 0 {
-			c.writer.Close()
+	var firstErr error
+	for i := range results {
+		result := &results[i]
+		if result.Err == nil {
+			// The response body of an error status code cannot be merged.
+			if len(c.url) > 1 && result.StatusCode >= http.StatusBadRequest {
+				result.Err = &StatusError{
+					URL:        result.URL,
+					Method:     result.Method,
+					Attempts:   result.Attempts,
+					StatusCode: result.StatusCode,
+					Body:       result.Body,
+				}
+			} else {
+				continue
+			}
+		} else if ctx.Err() != nil {
+			result.Err = &CanceledError{URL: result.URL, Method: result.Method, Err: ctx.Err()}
+		} else if result.Err == context.Canceled {
+			// Canceled because another HTTP call is failed.
+			result.Err = &CanceledError{URL: result.URL, Method: result.Method, Err: result.Err}
 
-			c.req.Header.SetContentType(c.writer.FormDataContentType())
-			c.req.SetBodyStream(&c.body, c.body.Len())
+			continue
 		}
 
-		// If the HTTP request uses HTTP retry, if HTTP is failed will be retrying.
-		finalResp, e := retry.New(&retry.Config{
-			Timeouts: c.timeout,
-			Attempts: c.retryAttempt,
-			Interval: c.retryInterval,
-		}).Do(c.req, resp, c.client)
-		if e != nil && e.Error() != "Request Timeout" {
-			return Response{}, e
-		} else if e != nil && e.Error() == "Request Timeout" {
-			return Response{
-				StatusCode: http.StatusRequestTimeout,
-			}, e
+		if firstErr == nil {
+			firstErr = result.Err
+			httpResponse.StatusCode = result.StatusCode
 		}
+	}
 
-		if len(c.url) == 1 {
-			headers = convertHeader(&finalResp.Header)
-			cookies = convertCookie(&finalResp.Header)
+	if firstErr != nil {
+		var timeoutErr *TimeoutError
+		if errors.As(firstErr, &timeoutErr) {
+			httpResponse.StatusCode = http.StatusRequestTimeout
 		}
 
-		// If calling more than one URL, the response body will be merged.
-		if len(row.whitelist) > 0 {
-			m.MergeFromWhitelist(row.whitelist, finalResp.Body())
-		} else if len(row.whitelist) == 0 {
-			m.Merge(row.blacklist, finalResp.Body())
-		}
+		return httpResponse, firstErr
+	}
+
+	// The response body is not merged if only calling 1 URL without filtering the field.
+	if len(c.url) == 1 && len(c.url[0].whitelist) == 0 && len(c.url[0].blacklist) == 0 {
+		httpResponse.Body = results[0].Body
+	} else {
+		m := merging.New()
+		start := time.Now()
+
+		for i, row := range c.url {
+			if len(results[i].Body) == 0 {
+				continue
+			}
 
-		statusCode = finalResp.StatusCode()
+			// If calling more than one URL, the response body will be merged.
+			var e error
+			if len(row.whitelist) > 0 {
+				e = m.MergeFromWhitelist(row.whitelist, results[i].Body)
+			} else if len(row.whitelist) == 0 {
+				e = m.Merge(row.blacklist, results[i].Body)
+			}
+			if e != nil {
+				results[i].Err = &MergeError{URL: row.url, Method: row.method, Err: e}
+				httpResponse.StatusCode = results[i].StatusCode
+				c.observeMerge(start, results[i].Err)
+
+				return httpResponse, results[i].Err
+			}
+		}
 
-		c.req.Reset()
-		finalResp.Reset()
+		httpResponse.Body = m.Get()
+		c.observeMerge(start, nil)
 	}
 
-	httpResponse := Response{
-		StatusCode: statusCode,
-		Headers:    headers,
-		Cookies:    cookies,
-		Body:       m.Get(),
+	if len(c.url) == 1 {
+		httpResponse.Headers = results[0].Headers
+		httpResponse.Cookies = results[0].Cookies
+		httpResponse.Redirects = results[0].Redirects
+		httpResponse.Cache = results[0].Cache
 	}
 
 	return httpResponse, nil
 }
+
+// fanOut to calling all the URL, the results have the same order as the URL.
+// The GET calls are made concurrently, limited by the max concurrency.
+// The other methods are made one by one in the declared order,
+// after the previous HTTP calls are finished and before the next HTTP calls are made.
+// If one of the HTTP calls fails, the remaining HTTP calls are canceled.
+func (c *Config) fanOut(ctx context.Context) []SourceResult {
+	results := make([]SourceResult, len(c.url))
+
+	if len(c.url) == 1 {
+		results[0] = c.callShared(ctx, c.url[0])
+
+		return results
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for start := 0; start < len(c.url); {
+		end := start + 1
+		if c.url[start].method == http.MethodGet {
+			for end < len(c.url) && c.url[end].method == http.MethodGet {
+				end++
+			}
+		}
+
+		c.fanOutGroup(ctx, cancel, c.url[start:end], results[start:end])
+		start = end
+	}
+
+	return results
+}
+
+// fanOutGroup to calling the URL of the group concurrently, limited by the max concurrency.
+func (c *Config) fanOutGroup(ctx context.Context, cancel context.CancelFunc, rows []urlConfig, results []SourceResult) {
+	concurrency := c.concurrency
+	if concurrency < 1 || concurrency > len(rows) {
+		concurrency = len(rows)
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, row := range rows {
+		wg.Add(1)
+		go func(i int, row urlConfig) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i] = SourceResult{URL: row.url, Method: row.method, Err: ctx.Err()}
+
+				return
+			}
+
+			results[i] = c.callShared(ctx, row)
+			if results[i].Err != nil {
+				cancel()
+			}
+		}(i, row)
+	}
+
+	wg.Wait()
+}
+
+// call to calling one URL with its own request,
+// the request is copied from the request configuration.
+func (c *Config) call(ctx context.Context, row urlConfig) SourceResult {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	c.header.CopyTo(&req.Header)
+	req.SetBody(c.body)
+	req.SetRequestURI(row.url)
+	req.Header.SetMethod(row.method)
+	if len(req.Header.Peek(fasthttp.HeaderAcceptEncoding)) == 0 {
+		req.Header.Set(fasthttp.HeaderAcceptEncoding, acceptEncoding)
+	}
+
+	if c.client.cache != nil {
+		if cacheReq, ok := c.cacheRequest(ctx, req); ok {
+			defer fasthttp.ReleaseRequest(cacheReq)
+
+			return c.callCache(ctx, row, req, cacheReq, resp)
+		}
+	}
+
+	return c.fetch(ctx, row, req, resp, nil, nil)
+}
+
+// fetch to call the HTTP with the circuit breaker and the HTTP retry.
+// If the cache request is set, the response is stored by the cache request.
+// If the stored response is set, it is revalidated with its validators.
+func (c *Config) fetch(ctx context.Context, row urlConfig, req *fasthttp.Request, resp *fasthttp.Response, cacheReq *fasthttp.Request, stored *cache.Entry) SourceResult {
+	host := string(req.Host())
+
+	if stored != nil && !c.client.cache.Conditional(req, stored) {
+		stored = nil
+	}
+
+	// If the circuit of the host is open, the HTTP call fails fast.
+	var circuit *breaker.Breaker
+	if c.client.breakers != nil {
+		circuit = c.client.breakers.Get(host)
+		if e := circuit.Allow(); e != nil {
+			return SourceResult{
+				URL:    row.url,
+				Method: row.method,
+				Err:    &CircuitOpenError{URL: row.url, Method: row.method, Host: host, Err: e},
+			}
+		}
+	}
+
+	// If the HTTP request uses HTTP retry, if HTTP is failed will be retrying.
+	r := retry.New(&retry.Config{
+		Timeouts:       c.timeout,
+		Attempts:       c.retryPolicy.Attempts,
+		Backoff:        c.retryPolicy.Backoff,
+		MaxElapsedTime: c.retryPolicy.MaxElapsedTime,
+		StatusCodes:    c.retryPolicy.StatusCodes,
+		Condition:      c.retryPolicy.Condition,
+		MaxRetryAfter:  c.retryPolicy.MaxRetryAfter,
+
+		RetryNonIdempotent: c.retryPolicy.RetryNonIdempotent || c.idempotencyKey,
+		OnRetry:            c.onRetry(row, host),
+	})
+
+	// Without a retry policy, the HTTP call is retried once like retry.New,
+	// otherwise the attempts of the policy are used, 0 means no retries.
+	if c.retryPolicySet {
+		r.Attempts = c.retryPolicy.Attempts
+	}
+
+	var redirects []Redirect
+
+	start := time.Now()
+	finalResp, e := r.DoContext(ctx, req, resp, c.doer(ctx, row, &redirects))
+
+	var cacheStatus CacheStatus
+	if e == nil && cacheReq != nil {
+		cacheStatus = c.cacheResponse(cacheReq, finalResp, stored, start, len(redirects) > 0)
+	}
+
+	result := SourceResult{
+		URL:      row.url,
+		Method:   row.method,
+		Duration: time.Since(start),
+		Attempts: r.RetryAttempts,
+		Cache:    cacheStatus,
+	}
+
+	if e != nil && ctx.Err() != nil {
+		result.Err = ctx.Err()
+	} else if e != nil {
+		result.Err = classifyError(row, r.RetryAttempts, e)
+	} else {
+		setResponse(&result, finalResp, c.client.hc.MaxResponseBodySize)
+		result.Redirects = redirects
+	}
+
+	// The HTTP call canceled by the context or by the rate limit is neither a success nor a failure of the host.
+	var rateLimitErr *RateLimitError
+	if circuit != nil {
+		if e != nil && (ctx.Err() != nil || errors.As(e, &rateLimitErr)) {
+			circuit.Cancel()
+		} else if e != nil || result.StatusCode >= http.StatusInternalServerError {
+			circuit.Failure()
+		} else {
+			circuit.Success()
+		}
+	}
+
+	return result
+}
+
+// setResponse to set the status code, headers, cookies and body of the response to the result.
+// The response body is decoded by the Content-Encoding header.
+func setResponse(result *SourceResult, resp *fasthttp.Response, maxBodySize int) {
+	if encoding, e := decodeBody(resp, maxBodySize); e != nil {
+		result.Err = &DecodeError{URL: result.URL, Method: result.Method, Encoding: encoding, Err: e}
+	}
+
+	result.StatusCode = resp.StatusCode()
+	result.Headers = convertHeader(&resp.Header)
+	result.Cookies = convertCookie(&resp.Header)
+	result.Body = append([]byte(nil), resp.Body()...)
+}
@@ -0,0 +1,123 @@
+package panggilhttp
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/KodepandaID/panggilhttp/pkg/singleflight"
+)
+
+// credentialHeaders are always significant, so the callers with different credentials never share a response.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// WithCoalescing to share 1 in-flight HTTP call between the concurrent identical GET calls,
+// the calls are identical if they have the same URL and the same significant headers.
+// If there are no significant headers, every header of the HTTP call is significant.
+// The Authorization, Proxy-Authorization and Cookie headers are always significant.
+// Every caller gets its own copy of the response before the whitelist and the blacklist,
+// so the fields filter of every caller still applies.
+// The HTTP call with a request body, with its own credentials or signer,
+// or with a timeout, a retry policy or a redirect configuration other than the client, is never coalesced.
+func (cl *Client) WithCoalescing(headers ...string) *Client {
+	cl.flights = singleflight.New()
+	cl.coalesceHeaders = nil
+	if len(headers) > 0 {
+		cl.coalesceHeaders = append(append([]string(nil), headers...), credentialHeaders...)
+	}
+
+	return cl
+}
+
+// callShared to share the HTTP call with the concurrent identical calls.
+// The shared HTTP call is not canceled if a caller stops waiting,
+// the caller gets the context error and the other callers still get the response.
+func (c *Config) callShared(ctx context.Context, row urlConfig) SourceResult {
+	key, ok := c.coalesceKey(row)
+	if !ok {
+		result := c.call(ctx, row)
+		c.observeRequest(result)
+
+		return result
+	}
+
+	// The shared HTTP call is observed once, and every caller which shares it is observed as coalesced.
+	v, shared, e := c.client.flights.Do(ctx, key, func() interface{} {
+		result := c.call(context.Background(), row)
+		c.observeRequest(result)
+
+		return result
+	})
+	if e != nil {
+		return SourceResult{URL: row.url, Method: row.method, Err: e}
+	}
+
+	result := copyResult(v.(SourceResult))
+	result.Coalesced = shared
+	if shared {
+		c.observeRequest(result)
+	}
+
+	return result
+}
+
+// coalesceKey to get the key of the HTTP call from the URL and the significant headers.
+// It returns false if the HTTP call can not be coalesced.
+func (c *Config) coalesceKey(row urlConfig) (string, bool) {
+	if c.client.flights == nil || row.method != http.MethodGet || len(c.body) > 0 || c.form.Len() > 0 || c.callCredentials {
+		return "", false
+	}
+
+	// The shared HTTP call runs with the configuration of the first caller,
+	// so only the HTTP calls with the client configuration are coalesced.
+	cl := c.client
+	if c.callPolicy || c.timeout != cl.timeout || c.maxRedirects != cl.maxRedirects ||
+		c.compression != cl.compression || c.compressionMinSize != cl.compressionMinSize {
+		return "", false
+	}
+
+	var headers []string
+	if len(c.client.coalesceHeaders) == 0 {
+		c.header.VisitAll(func(key, value []byte) {
+			headers = append(headers, strings.ToLower(string(key))+":"+string(value))
+		})
+	} else {
+		for _, name := range c.client.coalesceHeaders {
+			if value := c.header.Peek(name); len(value) > 0 {
+				headers = append(headers, strings.ToLower(name)+":"+string(value))
+			}
+		}
+	}
+	sort.Strings(headers)
+
+	return row.url + "\n" + strings.Join(headers, "\n"), true
+}
+
+// copyResult to copy the shared result, so every caller can change its own result.
+func copyResult(r SourceResult) SourceResult {
+	if r.Headers != nil {
+		headers := make(map[string]string, len(r.Headers))
+		for key, val := range r.Headers {
+			headers[key] = val
+		}
+		r.Headers = headers
+	}
+
+	if r.Cookies != nil {
+		cookies := make(map[string]string, len(r.Cookies))
+		for key, val := range r.Cookies {
+			cookies[key] = val
+		}
+		r.Cookies = cookies
+	}
+
+	if r.Body != nil {
+		r.Body = append([]byte(nil), r.Body...)
+	}
+	if r.Redirects != nil {
+		r.Redirects = append([]Redirect(nil), r.Redirects...)
+	}
+
+	return r
+}
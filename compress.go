@@ -0,0 +1,223 @@
+package panggilhttp
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+	"github.com/valyala/fasthttp"
+)
+
+// Content encodings, supported to decode the response body and to compress the request body.
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingBrotli  = "br"
+	EncodingZstd    = "zstd"
+)
+
+// acceptEncoding is the Accept-Encoding header if it is not set with WithHeader.
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// DefaultCompressionMinSize is the min request body size to compress, in bytes.
+const DefaultCompressionMinSize = 1024
+
+// DefaultMaxDecodedSize is the max size of the decoded response body, in bytes,
+// if the max response body size is not set with WithMaxResponseBodySize.
+const DefaultMaxDecodedSize = 64 << 20
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+)
+
+// WithMaxResponseBodySize to limit the response body size in bytes, before and after it is decoded.
+// By default, the response body before decoding has no limit,
+// and the decoded response body is limited by DefaultMaxDecodedSize.
+func (cl *Client) WithMaxResponseBodySize(size int) *Client {
+	if size < 0 {
+		cl.errs = append(cl.errs, ErrInvalidBodySize)
+
+		return cl
+	}
+
+	cl.hc.MaxResponseBodySize = size
+	cl.resetClients()
+
+	return cl
+}
+
+// WithRequestCompression to compress the JSON request body of every HTTP call
+// with the content encoding, like EncodingGzip, and to set the Content-Encoding header.
+// Only the request body of minSize bytes or more is compressed,
+// if minSize is less than 1, DefaultCompressionMinSize is used.
+func (cl *Client) WithRequestCompression(encoding string, minSize int) *Client {
+	if !supportedEncoding(encoding) {
+		cl.errs = append(cl.errs, ErrInvalidEncoding)
+
+		return cl
+	}
+
+	cl.compression = encoding
+	cl.compressionMinSize = minSize
+
+	return cl
+}
+
+// WithRequestCompression to compress the JSON request body with the content encoding,
+// like EncodingGzip, and to set the Content-Encoding header.
+// Only the request body of minSize bytes or more is compressed,
+// if minSize is less than 1, DefaultCompressionMinSize is used.
+func (c *Config) WithRequestCompression(encoding string, minSize int) *Config {
+	if !supportedEncoding(encoding) {
+		c.errs = append(c.errs, ErrInvalidEncoding)
+
+		return c
+	}
+
+	c.compression = encoding
+	c.compressionMinSize = minSize
+
+	return c
+}
+
+// compressBody to compress the JSON request body once,
+// the request body with the Content-Encoding header is never compressed again.
+func (c *Config) compressBody() {
+	if c.compression == "" || !c.jsonBody || len(c.header.Peek(fasthttp.HeaderContentEncoding)) > 0 {
+		return
+	}
+
+	minSize := c.compressionMinSize
+	if minSize < 1 {
+		minSize = DefaultCompressionMinSize
+	}
+	if len(c.body) < minSize {
+		return
+	}
+
+	c.body = encode(c.compression, c.body)
+	c.header.Set(fasthttp.HeaderContentEncoding, c.compression)
+}
+
+// decodeBody to decode the response body by the Content-Encoding header,
+// the Content-Encoding header is removed if the response body is decoded.
+// The response body with an unsupported content encoding, or an empty response body, is not decoded.
+// The decoded response body larger than the limit fails with ErrBodyTooLarge.
+func decodeBody(resp *fasthttp.Response, limit int) (string, error) {
+	header := string(resp.Header.Peek(fasthttp.HeaderContentEncoding))
+	if header == "" || len(resp.Body()) == 0 {
+		return "", nil
+	}
+
+	if limit <= 0 {
+		limit = DefaultMaxDecodedSize
+	}
+
+	encodings := strings.Split(header, ",")
+	for i := range encodings {
+		encodings[i] = strings.ToLower(strings.TrimSpace(encodings[i]))
+		if encodings[i] != "identity" && !supportedEncoding(encodings[i]) {
+			return "", nil
+		}
+	}
+
+	// The content encodings are listed in the order they are applied.
+	body := resp.Body()
+	for i := len(encodings) - 1; i >= 0; i-- {
+		if encodings[i] == "identity" {
+			continue
+		}
+
+		var e error
+		if body, e = decode(encodings[i], body, limit); e != nil {
+			return encodings[i], e
+		}
+	}
+
+	resp.SetBody(body)
+	resp.Header.Del(fasthttp.HeaderContentEncoding)
+	resp.Header.SetContentLength(len(body))
+
+	return "", nil
+}
+
+func supportedEncoding(encoding string) bool {
+	switch encoding {
+	case EncodingGzip, EncodingDeflate, EncodingBrotli, EncodingZstd:
+		return true
+	}
+
+	return false
+}
+
+func encode(encoding string, body []byte) []byte {
+	switch encoding {
+	case EncodingGzip:
+		return fasthttp.AppendGzipBytes(nil, body)
+	case EncodingDeflate:
+		return fasthttp.AppendDeflateBytes(nil, body)
+	case EncodingBrotli:
+		return fasthttp.AppendBrotliBytes(nil, body)
+	case EncodingZstd:
+		initZstd()
+
+		return zstdEncoder.EncodeAll(body, nil)
+	}
+
+	return body
+}
+
+func decode(encoding string, body []byte, limit int) ([]byte, error) {
+	var r io.Reader
+	switch encoding {
+	case EncodingGzip:
+		gr, e := gzip.NewReader(bytes.NewReader(body))
+		if e != nil {
+			return nil, e
+		}
+		defer gr.Close()
+		r = gr
+	case EncodingDeflate:
+		zr, e := zlib.NewReader(bytes.NewReader(body))
+		if e != nil {
+			return nil, e
+		}
+		defer zr.Close()
+		r = zr
+	case EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	case EncodingZstd:
+		zr, e := zstd.NewReader(bytes.NewReader(body), zstd.WithDecoderConcurrency(1))
+		if e != nil {
+			return nil, e
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return body, nil
+	}
+
+	// Read 1 byte more than the limit to know if the decoded response body is too large.
+	var buf bytes.Buffer
+	if _, e := io.Copy(&buf, io.LimitReader(r, int64(limit)+1)); e != nil {
+		return nil, e
+	}
+	if buf.Len() > limit {
+		return nil, ErrBodyTooLarge
+	}
+
+	return buf.Bytes(), nil
+}
+
+// initZstd to create the zstd encoder once, it is safe for concurrent use with EncodeAll.
+func initZstd() {
+	zstdOnce.Do(func() {
+		zstdEncoder, _ = zstd.NewWriter(nil)
+	})
+}
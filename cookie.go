@@ -0,0 +1,55 @@
+package panggilhttp
+
+import (
+	"net/url"
+
+	"github.com/KodepandaID/panggilhttp/pkg/cookiejar"
+	"github.com/valyala/fasthttp"
+)
+
+// WithCookieJar to store the received cookies and send them automatically on the next HTTP calls,
+// by the domain, path and expiry rules of RFC 6265.
+// A cookie set by WithCookie has precedence over the cookie in the jar with the same name.
+func (cl *Client) WithCookieJar(jar *cookiejar.Jar) *Client {
+	cl.jar = jar
+
+	return cl
+}
+
+// CookieJar to get the cookie jar of the client, nil if not set.
+func (cl *Client) CookieJar() *cookiejar.Jar {
+	return cl.jar
+}
+
+// setJarCookies to add the cookies of the jar to the request.
+func setJarCookies(jar *cookiejar.Jar, req *fasthttp.Request) {
+	u, e := url.Parse(req.URI().String())
+	if e != nil {
+		return
+	}
+
+	for _, cookie := range jar.Cookies(u) {
+		if len(req.Header.Cookie(cookie.Name)) == 0 {
+			req.Header.SetCookie(cookie.Name, cookie.Value)
+		}
+	}
+}
+
+// storeJarCookies to store the Set-Cookie headers of the response to the jar.
+func storeJarCookies(jar *cookiejar.Jar, req *fasthttp.Request, resp *fasthttp.Response) {
+	setCookies := make([]string, 0)
+	resp.Header.VisitAllCookie(func(key, value []byte) {
+		setCookies = append(setCookies, string(value))
+	})
+
+	if len(setCookies) == 0 {
+		return
+	}
+
+	u, e := url.Parse(req.URI().String())
+	if e != nil {
+		return
+	}
+
+	jar.SetCookies(u, setCookies)
+}
@@ -0,0 +1,348 @@
+package panggilhttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/KodepandaID/panggilhttp/pkg/breaker"
+	"github.com/KodepandaID/panggilhttp/pkg/ratelimit"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	// ErrInvalidTimeout is the configuration error of WithTimeout.
+	ErrInvalidTimeout = errors.New("timeout value cannot be less than 1")
+	// ErrInvalidRetry is the configuration error of WithFailRetry.
+	ErrInvalidRetry = errors.New("interval or attempts value cannot be less than 1")
+	// ErrNoURL is the configuration error of Do if there is no URL to call.
+	ErrNoURL = errors.New("no URL to call, use Get, Post, Put, Patch or Delete")
+	// ErrNilFile is the configuration error of SendFile.
+	ErrNilFile = errors.New("file cannot be nil")
+	// ErrInvalidRateLimit is the configuration error of WithRateLimit.
+	ErrInvalidRateLimit = errors.New("rate limit requests cannot be less than 1")
+	// ErrInvalidRedirects is the configuration error of WithRedirects.
+	ErrInvalidRedirects = errors.New("max redirects cannot be less than 0")
+	// ErrInvalidProxy is the configuration error of WithProxy and WithProxyFromEnvironment.
+	ErrInvalidProxy = errors.New("invalid proxy URL")
+	// ErrInvalidPool is the configuration error of WithPool.
+	ErrInvalidPool = errors.New("pool configuration cannot be less than 0")
+	// ErrInvalidCertificate is the configuration error of WithClientCertificate.
+	ErrInvalidCertificate = errors.New("invalid client certificate")
+	// ErrInvalidTLSVersion is the configuration error of WithMinTLSVersion.
+	ErrInvalidTLSVersion = errors.New("invalid TLS version")
+	// ErrInvalidPin is the configuration error of WithPinnedPublicKeys.
+	ErrInvalidPin = errors.New("invalid public key pin")
+	// ErrInvalidEncoding is the configuration error of WithRequestCompression.
+	ErrInvalidEncoding = errors.New("unsupported content encoding")
+	// ErrInvalidBodySize is the configuration error of WithMaxResponseBodySize.
+	ErrInvalidBodySize = errors.New("max response body size cannot be less than 0")
+
+	// ErrTooManyRedirects is the error of a *RedirectError if the max redirects is exceeded.
+	ErrTooManyRedirects = errors.New("stopped after too many redirects")
+	// ErrBodyTooLarge is the error of a *DecodeError if the decoded response body is larger than the limit.
+	ErrBodyTooLarge = errors.New("decoded response body is too large")
+	// ErrPinMismatch is the error of a *PinError.
+	ErrPinMismatch = errors.New("public key pin mismatch")
+)
+
+// BuilderError is returned by Do if the HTTP call configuration is invalid,
+// it contains every configuration error in the order they are made.
+type BuilderError struct {
+	Errs []error
+}
+
+func (e *BuilderError) Error() string {
+	msg := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msg = append(msg, err.Error())
+	}
+
+	return "invalid configuration: " + strings.Join(msg, "; ")
+}
+
+// Unwrap to get the configuration errors.
+func (e *BuilderError) Unwrap() []error {
+	return e.Errs
+}
+
+// Is to check if one of the configuration errors matches the target.
+func (e *BuilderError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanceledError is returned when the context of DoContext is canceled
+// or the deadline is exceeded before the HTTP call is done.
+// Use errors.Is with context.Canceled or context.DeadlineExceeded to check the reason.
+type CanceledError struct {
+	URL    string
+	Method string
+	Err    error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Err)
+}
+
+// Unwrap to get the context error.
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is returned when the HTTP call is timeout in every attempt,
+// including dial and TLS handshake timeout.
+type TimeoutError struct {
+	URL      string
+	Method   string
+	Attempts int
+	Err      error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s %s: request timeout after %d attempts: %s", e.Method, e.URL, e.Attempts, e.Err)
+}
+
+// Unwrap to get the fasthttp error.
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout to implement net.Error.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// ConnectError is returned when the connection to the host cannot be made or is closed,
+// like a refused connection or a TLS handshake failure.
+type ConnectError struct {
+	URL      string
+	Method   string
+	Attempts int
+	Err      error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("%s %s: connection failed after %d attempts: %s", e.Method, e.URL, e.Attempts, e.Err)
+}
+
+// Unwrap to get the fasthttp error.
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// DNSError is returned when the host cannot be resolved.
+type DNSError struct {
+	URL      string
+	Method   string
+	Attempts int
+	Err      error
+}
+
+func (e *DNSError) Error() string {
+	return fmt.Sprintf("%s %s: DNS lookup failed after %d attempts: %s", e.Method, e.URL, e.Attempts, e.Err)
+}
+
+// Unwrap to get the *net.DNSError.
+func (e *DNSError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned when one of the URL responds with an error status code,
+// when calling more than one URL, the response body cannot be merged.
+type StatusError struct {
+	URL        string
+	Method     string
+	Attempts   int
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status code %d", e.Method, e.URL, e.StatusCode)
+}
+
+// MergeError is returned when the response body cannot be merged,
+// like the response body is not a JSON object.
+type MergeError struct {
+	URL    string
+	Method string
+	Err    error
+}
+
+func (e *MergeError) Error() string {
+	return fmt.Sprintf("%s %s: cannot merge the response body: %s", e.Method, e.URL, e.Err)
+}
+
+// Unwrap to get the JSON error.
+func (e *MergeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is returned when the response body cannot be decoded by the Content-Encoding header.
+type DecodeError struct {
+	URL      string
+	Method   string
+	Encoding string
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s %s: cannot decode the %s response body: %s", e.Method, e.URL, e.Encoding, e.Err)
+}
+
+// Unwrap to get the decoder error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ErrCircuitOpen is the error of a HTTP call to a host with an open circuit,
+// use errors.Is to check a *CircuitOpenError.
+var ErrCircuitOpen = breaker.ErrCircuitOpen
+
+// CircuitOpenError is returned when the circuit of the host is open,
+// the HTTP call is not made.
+type CircuitOpenError struct {
+	URL    string
+	Method string
+	Host   string
+	Err    error
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s %s: %s for %s", e.Method, e.URL, e.Err, e.Host)
+}
+
+// Unwrap to get ErrCircuitOpen.
+func (e *CircuitOpenError) Unwrap() error {
+	return e.Err
+}
+
+// ErrRateLimited is the error of a HTTP call over the rate limit,
+// use errors.Is to check a *RateLimitError.
+var ErrRateLimited = ratelimit.ErrLimited
+
+// RateLimitError is returned when the rate limit is exceeded,
+// the request is not sent and the HTTP call is not retried.
+type RateLimitError struct {
+	URL    string
+	Method string
+	Host   string
+	Err    error
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.URL, e.Err)
+}
+
+// Unwrap to get ErrRateLimited.
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent to stop the HTTP retry, the rate limit fails fast instead of waiting.
+func (e *RateLimitError) Permanent() bool {
+	return true
+}
+
+// AuthError is returned when the credentials cannot be set,
+// like the TokenSource fails to get a token or the Signer fails to sign the request.
+type AuthError struct {
+	URL    string
+	Method string
+	Err    error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("%s %s: authentication failed: %s", e.Method, e.URL, e.Err)
+}
+
+// Unwrap to get the TokenSource or Signer error.
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent to stop the HTTP retry, the request is never sent without the credentials.
+func (e *AuthError) Permanent() bool {
+	return true
+}
+
+// RedirectError is returned when the redirect cannot be followed,
+// like the max redirects is exceeded or the CheckRedirectFunc returns an error.
+type RedirectError struct {
+	URL       string
+	Method    string
+	Redirects []Redirect
+	Err       error
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("%s %s: redirect failed after %d redirects: %s", e.Method, e.URL, len(e.Redirects), e.Err)
+}
+
+// Unwrap to get ErrTooManyRedirects or the CheckRedirectFunc error.
+func (e *RedirectError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent to stop the HTTP retry, following the redirects again gives the same error.
+func (e *RedirectError) Permanent() bool {
+	return true
+}
+
+// PinError is returned when none of the server certificates matches the pinned public keys,
+// the connection is closed before sending the request.
+type PinError struct {
+	URL    string
+	Method string
+	Host   string
+	// Pins is the SPKI pins of the server certificates.
+	Pins []string
+}
+
+func (e *PinError) Error() string {
+	return fmt.Sprintf("%s %s: %s for %s", e.Method, e.URL, ErrPinMismatch, e.Host)
+}
+
+// Unwrap to get ErrPinMismatch.
+func (e *PinError) Unwrap() error {
+	return ErrPinMismatch
+}
+
+// classifyError to wrap the HTTP call error to the typed error.
+func classifyError(row urlConfig, attempts int, e error) error {
+	var (
+		authErr      *AuthError
+		redirectErr  *RedirectError
+		rateLimitErr *RateLimitError
+		pinErr       *pinMismatch
+		dnsErr       *net.DNSError
+		timeoutErr   interface{ Timeout() bool }
+	)
+
+	switch {
+	case errors.As(e, &authErr):
+		return authErr
+	case errors.As(e, &rateLimitErr):
+		return rateLimitErr
+	case errors.As(e, &redirectErr):
+		return redirectErr
+	case errors.As(e, &pinErr):
+		return &PinError{URL: row.url, Method: row.method, Host: pinErr.host, Pins: pinErr.pins}
+	case errors.As(e, &dnsErr):
+		return &DNSError{URL: row.url, Method: row.method, Attempts: attempts, Err: e}
+	case errors.Is(e, fasthttp.ErrTimeout),
+		errors.Is(e, fasthttp.ErrDialTimeout),
+		errors.Is(e, fasthttp.ErrTLSHandshakeTimeout),
+		errors.As(e, &timeoutErr) && timeoutErr.Timeout():
+		return &TimeoutError{URL: row.url, Method: row.method, Attempts: attempts, Err: e}
+	default:
+		return &ConnectError{URL: row.url, Method: row.method, Attempts: attempts, Err: e}
+	}
+}
@@ -3,6 +3,8 @@ module github.com/KodepandaID/panggilhttp
 go 1.15
 
 require (
+	github.com/andybalholm/brotli v1.0.1
+	github.com/klauspost/compress v1.11.8
 	github.com/stretchr/testify v1.7.0
 	github.com/valyala/fasthttp v1.22.0
 	github.com/valyala/fastjson v1.6.3
@@ -3,11 +3,13 @@ package panggilhttp
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
-	"log"
 	"mime/multipart"
 	"net/http"
 	"time"
+
+	"github.com/KodepandaID/panggilhttp/pkg/retry"
 )
 
 // Get to set HTTP GET method.
@@ -69,7 +71,7 @@ func (c *Config) Delete(url string) *Config {
 // WithHeader to set HTTP headers.
 func (c *Config) WithHeader(headers map[string]string) *Config {
 	for key, val := range headers {
-		c.req.Header.Set(key, val)
+		c.header.Set(key, val)
 	}
 
 	return c
@@ -78,7 +80,7 @@ func (c *Config) WithHeader(headers map[string]string) *Config {
 // WithCookie to send HTTP cookies.
 func (c *Config) WithCookie(cookies map[string]string) *Config {
 	for key, val := range cookies {
-		c.req.Header.SetCookie(key, val)
+		c.header.SetCookie(key, val)
 	}
 
 	return c
@@ -88,7 +90,9 @@ func (c *Config) WithCookie(cookies map[string]string) *Config {
 // The default value is 1 seconds.
 func (c *Config) WithTimeout(second time.Duration) *Config {
 	if second < 1 {
-		log.Fatal("Timeout value cannot be less than 1.")
+		c.errs = append(c.errs, ErrInvalidTimeout)
+
+		return c
 	}
 
 	c.timeout = time.Second * second
@@ -96,17 +100,74 @@ func (c *Config) WithTimeout(second time.Duration) *Config {
 	return c
 }
 
+// WithConcurrency to set the max HTTP calls running at the same time,
+// if calling more than one URL with the GET method.
+// The default value is 0, all the GET calls are running at the same time.
+// The other methods are always called one by one in the declared order.
+func (c *Config) WithConcurrency(max int) *Config {
+	c.concurrency = max
+
+	return c
+}
+
 // WithFailRetry to retrying if HTTP call fails.
 // Use 2 argument interval and attempt.
 // interval args in miliseconds.
 // attempt args is int how much to retry HTTP calls.
+// The POST and PATCH method is not retried, unless WithIdempotencyKey is used.
 func (c *Config) WithFailRetry(interval time.Duration, attempt int) *Config {
 	if interval < 1 || attempt < 1 {
-		log.Fatal("Interval or Attempts value cannot be less than 1.")
+		c.errs = append(c.errs, ErrInvalidRetry)
+
+		return c
+	}
+
+	c.retryPolicy = retry.Policy{
+		Attempts: attempt,
+		Backoff:  retry.Constant{Interval: time.Millisecond * interval},
 	}
+	c.retryPolicySet = true
+	c.callPolicy = true
+
+	return c
+}
 
-	c.retryInterval = time.Millisecond * interval
-	c.retryAttempt = attempt
+// WithIdempotencyKey to retry the POST and PATCH method if HTTP call fails.
+// By default, only the idempotent method is retried to avoid a duplicate action.
+// A generated Idempotency-Key header is attached and reused in every attempt,
+// if the header is not set with WithHeader.
+func (c *Config) WithIdempotencyKey() *Config {
+	c.idempotencyKey = true
+
+	return c
+}
+
+// WithRetryPolicy to set the HTTP retry policy,
+// like the backoff between attempts and the max elapsed time of retrying.
+// For example, exponential backoff with jitter:
+//
+//	WithRetryPolicy(retry.Policy{
+//		Attempts:       5,
+//		Backoff:        retry.FullJitter{Base: 100 * time.Millisecond, Max: 2 * time.Second},
+//		MaxElapsedTime: 5 * time.Second,
+//	})
+//
+// To retry by the response status code and respect the Retry-After header:
+//
+//	WithRetryPolicy(retry.Policy{
+//		Attempts:    3,
+//		StatusCodes: retry.DefaultStatusCodes,
+//	})
+func (c *Config) WithRetryPolicy(policy retry.Policy) *Config {
+	if policy.Attempts < 0 || policy.MaxElapsedTime < 0 || policy.MaxRetryAfter < 0 {
+		c.errs = append(c.errs, ErrInvalidRetry)
+
+		return c
+	}
+
+	c.retryPolicy = policy
+	c.retryPolicySet = true
+	c.callPolicy = true
 
 	return c
 }
@@ -115,23 +176,29 @@ func (c *Config) WithFailRetry(interval time.Duration, attempt int) *Config {
 func (c *Config) SendJSON(j map[string]interface{}) *Config {
 	data, e := json.Marshal(j)
 	if e != nil {
-		log.Fatal("Failed to marshalling the JSON data")
+		c.errs = append(c.errs, fmt.Errorf("failed to marshalling the JSON data: %w", e))
+
+		return c
 	}
 
-	c.req.Header.SetContentType("application/json")
-	c.req.SetBody(data)
+	c.header.SetContentType("application/json")
+	c.body = data
+	c.jsonBody = true
 
 	return c
 }
 
 // SendFormData to send multipart/form-data with POST, PUT or PATCH method.
 func (c *Config) SendFormData(fd map[string]string) *Config {
-	if c.body.Len() == 0 {
-		c.writer = multipart.NewWriter(&c.body)
+	if c.writer == nil {
+		c.form.Reset()
+		c.writer = multipart.NewWriter(&c.form)
 	}
 
 	for key, val := range fd {
-		c.writer.WriteField(key, val)
+		if e := c.writer.WriteField(key, val); e != nil {
+			c.errs = append(c.errs, fmt.Errorf("write form field error: %w", e))
+		}
 	}
 
 	return c
@@ -139,22 +206,27 @@ func (c *Config) SendFormData(fd map[string]string) *Config {
 
 // SendFile to send file with POST, PUT or PATCH method.
 func (c *Config) SendFile(key, filename string, file []byte) *Config {
-	if c.body.Len() == 0 {
-		c.writer = multipart.NewWriter(&c.body)
+	if file == nil {
+		c.errs = append(c.errs, ErrNilFile)
+
+		return c
 	}
 
-	if file == nil {
-		log.Fatal("File cannot be nil")
+	if c.writer == nil {
+		c.form.Reset()
+		c.writer = multipart.NewWriter(&c.form)
 	}
 
 	form, e := c.writer.CreateFormFile(key, filename)
 	if e != nil {
-		log.Fatalf("Create form error: %s", e)
+		c.errs = append(c.errs, fmt.Errorf("create form error: %w", e))
+
+		return c
 	}
 
 	r := bytes.NewReader(file)
 	if _, e := io.Copy(form, r); e != nil {
-		log.Fatalf("Write form file error: %s", e)
+		c.errs = append(c.errs, fmt.Errorf("write form file error: %w", e))
 	}
 
 	return c
@@ -0,0 +1,106 @@
+package panggilhttp
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp/pkg/metrics"
+	"github.com/valyala/fasthttp"
+)
+
+// MetricsCollector collects the metrics of the HTTP calls, like metrics.NewPrometheus.
+type MetricsCollector = metrics.Collector
+
+// WithMetrics to collect the metrics of every HTTP call, like the request count,
+// the duration, the retries, the timeouts, the bytes and the merge duration.
+// The response served by the cache or by a coalesced HTTP call is observed with its own source.
+// The collector must be safe for concurrent use.
+func (cl *Client) WithMetrics(collector MetricsCollector) *Client {
+	cl.metrics = collector
+
+	return cl
+}
+
+// observeRequest to collect the metrics of calling one URL after the HTTP call is done,
+// with the source of the response.
+func (c *Config) observeRequest(result SourceResult) {
+	if c.client.metrics == nil {
+		return
+	}
+
+	var timeoutErr *TimeoutError
+	m := metrics.Request{
+		Method:        result.Method,
+		Host:          urlHost(result.URL),
+		Source:        metrics.SourceUpstream,
+		StatusCode:    result.StatusCode,
+		Duration:      result.Duration,
+		Attempts:      result.Attempts,
+		Timeout:       errors.As(result.Err, &timeoutErr),
+		BytesSent:     len(c.body),
+		BytesReceived: len(result.Body),
+		Err:           result.Err,
+	}
+
+	switch {
+	case result.Coalesced:
+		m.Source = metrics.SourceCoalesced
+		m.BytesSent = 0
+	case result.Attempts == 0 && (result.Cache == CacheHit || result.Cache == CacheStale):
+		m.Source = metrics.SourceCache
+		m.BytesSent = 0
+	}
+
+	c.client.metrics.ObserveRequest(m)
+}
+
+// observeMerge to collect the metrics of merging the response bodies.
+func (c *Config) observeMerge(start time.Time, e error) {
+	if c.client.metrics == nil {
+		return
+	}
+
+	c.client.metrics.ObserveMerge(metrics.Merge{
+		Sources:  len(c.url),
+		Duration: time.Since(start),
+		Err:      e,
+	})
+}
+
+// onRetry to get the retry hook which collects the metrics of every retried HTTP attempt.
+func (c *Config) onRetry(row urlConfig, host string) func(int, *fasthttp.Response, error, time.Duration) {
+	if c.client.metrics == nil {
+		return nil
+	}
+
+	return func(attempt int, resp *fasthttp.Response, e error, wait time.Duration) {
+		r := metrics.Retry{
+			Method:  row.method,
+			Host:    host,
+			Attempt: attempt,
+			Reason:  metrics.ReasonError,
+			Wait:    wait,
+		}
+
+		var timeoutErr interface{ Timeout() bool }
+		if e == nil {
+			r.Reason = metrics.ReasonStatus
+			r.StatusCode = resp.StatusCode()
+		} else if errors.As(e, &timeoutErr) && timeoutErr.Timeout() {
+			r.Reason = metrics.ReasonTimeout
+		}
+
+		c.client.metrics.ObserveRetry(r)
+	}
+}
+
+// urlHost to get the host of the URL with the port, if the URL has a port.
+func urlHost(rawURL string) string {
+	u, e := url.Parse(rawURL)
+	if e != nil {
+		return ""
+	}
+
+	return u.Host
+}
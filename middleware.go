@@ -0,0 +1,141 @@
+package panggilhttp
+
+import (
+	"context"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp/pkg/ratelimit"
+	"github.com/KodepandaID/panggilhttp/pkg/retry"
+	"github.com/valyala/fasthttp"
+)
+
+// Handler is an HTTP attempt, it fills the response of the request.
+type Handler func(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error
+
+// Middleware wraps every HTTP attempt, including the retry.
+// A middleware can read or modify the request before calling next,
+// inspect the response after calling next,
+// or fill the response without calling next to short-circuit the HTTP attempt.
+type Middleware func(next Handler) Handler
+
+// Use to add middlewares for every HTTP call,
+// the first middleware is the outermost.
+func (cl *Client) Use(middlewares ...Middleware) *Client {
+	cl.middlewares = append(cl.middlewares, middlewares...)
+
+	return cl
+}
+
+// doer to create the HTTP attempt with the middlewares for the HTTP retry.
+// The followed redirects of the last attempt are set to the chain.
+func (c *Config) doer(ctx context.Context, row urlConfig, chain *[]Redirect) retry.Doer {
+	middlewares := c.client.middlewares
+	if len(middlewares) == 0 && !c.hasWireStep() {
+		return retry.DoerFunc(func(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+			if e := c.take(ctx, row, req); e != nil {
+				return e
+			}
+
+			return c.client.doDeadline(req, resp, time.Now().Add(timeout))
+		})
+	}
+
+	return retry.DoerFunc(func(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+		h := Handler(func(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error {
+			return c.send(ctx, row, req, resp, timeout, chain)
+		})
+
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			h = middlewares[i](h)
+		}
+
+		return h(ctx, req, resp)
+	})
+}
+
+// hasWireStep to check if the request is changed before it is sent.
+func (c *Config) hasWireStep() bool {
+	return len(c.auths) > 0 || c.signer != nil || c.client.jar != nil || c.maxRedirects > 0
+}
+
+// send to send the request after the middlewares, and follow the redirects.
+func (c *Config) send(ctx context.Context, row urlConfig, req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration, chain *[]Redirect) error {
+	deadline := time.Now().Add(timeout)
+	if !c.hasWireStep() {
+		if e := c.take(ctx, row, req); e != nil {
+			return e
+		}
+
+		return c.client.doDeadline(req, resp, deadline)
+	}
+
+	if c.maxRedirects > 0 {
+		return c.follow(ctx, row, req, resp, deadline, chain)
+	}
+
+	return c.sendHop(ctx, row, req, resp, deadline, true)
+}
+
+// sendHop to send the request once.
+// The cookies, the credentials and the signature are set to a copy of the request,
+// so the middlewares never see the credentials.
+// The credentials and the signature are only set if the host is trusted.
+func (c *Config) sendHop(ctx context.Context, row urlConfig, req *fasthttp.Request, resp *fasthttp.Response, deadline time.Time, trusted bool) error {
+	wireReq := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(wireReq)
+
+	for refreshed := false; ; refreshed = true {
+		req.CopyTo(wireReq)
+		if c.client.jar != nil {
+			setJarCookies(c.client.jar, wireReq)
+		}
+
+		if trusted {
+			for _, auth := range c.auths {
+				if e := auth.set(ctx, wireReq); e != nil {
+					return &AuthError{URL: row.url, Method: row.method, Err: e}
+				}
+			}
+
+			// The request is signed after the body and the credentials are final.
+			if c.signer != nil {
+				if e := c.signer.Sign(wireReq); e != nil {
+					return &AuthError{URL: row.url, Method: row.method, Err: e}
+				}
+			}
+		}
+
+		if e := c.take(ctx, row, wireReq); e != nil {
+			return e
+		}
+
+		e := c.client.doDeadline(wireReq, resp, deadline)
+		if e == nil && c.client.jar != nil {
+			storeJarCookies(c.client.jar, wireReq, resp)
+		}
+
+		// The rejected token is refreshed once.
+		if e != nil || refreshed || !trusted || resp.StatusCode() != fasthttp.StatusUnauthorized ||
+			!invalidateToken(c.auths, wireReq) {
+			return e
+		}
+	}
+}
+
+// take to wait for a token of the host before sending the request,
+// or to fail fast with a *RateLimitError if the rate limit is exceeded.
+// Every request on the wire takes a token, like the retries and the redirects.
+func (c *Config) take(ctx context.Context, row urlConfig, req *fasthttp.Request) error {
+	if c.client.limiters == nil {
+		return nil
+	}
+
+	host := string(req.Host())
+	if e := c.client.limiters.Take(ctx, host); e == ratelimit.ErrLimited {
+		return &RateLimitError{URL: row.url, Method: row.method, Host: host, Err: e}
+	} else if e != nil {
+		return e
+	}
+
+	return nil
+}
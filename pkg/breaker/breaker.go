@@ -0,0 +1,304 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by Allow if the circuit is open,
+// or the half-open circuit has no more trial request.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State is the state of a circuit.
+type State int
+
+const (
+	// Closed is the normal state, every request is allowed.
+	Closed State = iota
+	// Open is the failed state, every request fails fast until the cooldown is over.
+	Open
+	// HalfOpen is the trial state after the cooldown, a few requests are allowed
+	// to check if the host is recovered.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// Config is a circuit breaker configuration.
+type Config struct {
+	// ConsecutiveFailures to open the circuit after the consecutive failures.
+	ConsecutiveFailures int
+	// FailureRate to open the circuit if the failure rate is reached, between 0 and 1.
+	// The failure rate is checked after MinRequests in the Window.
+	FailureRate float64
+	// MinRequests is how much request in the Window before the failure rate is checked.
+	// The default value is 10.
+	MinRequests int
+	// Window to reset the request counts in the closed state, 0 means never.
+	Window time.Duration
+
+	// Cooldown is how long the circuit is open before half-open.
+	// The default value is 30 seconds.
+	Cooldown time.Duration
+	// HalfOpenRequests is how much trial request in the half-open state.
+	// The default value is 1, and it is at least HalfOpenSuccesses.
+	HalfOpenRequests int
+	// HalfOpenSuccesses is how much succeeded trial request to close the circuit,
+	// any failed trial request opens the circuit again.
+	// The default value is 1, so the first succeeded trial request closes the circuit.
+	HalfOpenSuccesses int
+
+	// OnStateChange is called when the state of a circuit is changed.
+	OnStateChange func(key string, from, to State)
+}
+
+// Breaker is a circuit, safe for concurrent use.
+type Breaker struct {
+	key string
+	cfg Config
+
+	mu                  sync.Mutex
+	state               State
+	requests            int
+	failures            int
+	consecutiveFailures int
+	halfOpenRequests    int
+	halfOpenSuccesses   int
+	windowStart         time.Time
+	openedAt            time.Time
+	changes             [][2]State // state changes from and to, to notify without the lock
+}
+
+// New to create a new circuit with a key, usually the host.
+// If both ConsecutiveFailures and FailureRate are not set,
+// the circuit is opened after 5 consecutive failures.
+func New(key string, cfg *Config) *Breaker {
+	c := *cfg
+	if c.ConsecutiveFailures < 1 && c.FailureRate <= 0 {
+		c.ConsecutiveFailures = 5
+	}
+
+	if c.Cooldown <= 0 {
+		c.Cooldown = 30 * time.Second
+	}
+
+	if c.FailureRate > 0 && c.MinRequests < 1 {
+		c.MinRequests = 10
+	}
+
+	if c.HalfOpenSuccesses < 1 {
+		c.HalfOpenSuccesses = 1
+	}
+
+	if c.HalfOpenRequests < c.HalfOpenSuccesses {
+		c.HalfOpenRequests = c.HalfOpenSuccesses
+	}
+
+	return &Breaker{
+		key:         key,
+		cfg:         c,
+		windowStart: time.Now(),
+	}
+}
+
+// State to get the current state of the circuit.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	state := b.currentState(time.Now())
+	changes := b.takeChanges()
+	b.mu.Unlock()
+
+	b.notify(changes)
+
+	return state
+}
+
+// Allow to check if a request is allowed,
+// every allowed request must be reported with Success, Failure or Cancel.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	state := b.currentState(time.Now())
+
+	var e error
+	switch state {
+	case Open:
+		e = ErrCircuitOpen
+	case HalfOpen:
+		if b.halfOpenRequests >= b.cfg.HalfOpenRequests {
+			e = ErrCircuitOpen
+		} else {
+			b.halfOpenRequests++
+		}
+	}
+	changes := b.takeChanges()
+	b.mu.Unlock()
+
+	b.notify(changes)
+
+	return e
+}
+
+// Success to report a succeeded request.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	now := time.Now()
+
+	switch b.currentState(now) {
+	case Closed:
+		b.requests++
+		b.consecutiveFailures = 0
+	case HalfOpen:
+		b.halfOpenSuccesses++
+		if b.halfOpenSuccesses >= b.cfg.HalfOpenSuccesses {
+			b.setState(Closed, now)
+		}
+	}
+	changes := b.takeChanges()
+	b.mu.Unlock()
+
+	b.notify(changes)
+}
+
+// Failure to report a failed request.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	now := time.Now()
+
+	switch b.currentState(now) {
+	case Closed:
+		b.requests++
+		b.failures++
+		b.consecutiveFailures++
+
+		if b.shouldOpen() {
+			b.setState(Open, now)
+		}
+	case HalfOpen:
+		b.setState(Open, now)
+	}
+	changes := b.takeChanges()
+	b.mu.Unlock()
+
+	b.notify(changes)
+}
+
+// Cancel to report a request which is canceled before it succeeded or failed,
+// it is not counted and its trial request in the half-open state is released.
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	if b.currentState(time.Now()) == HalfOpen && b.halfOpenRequests > 0 {
+		b.halfOpenRequests--
+	}
+	changes := b.takeChanges()
+	b.mu.Unlock()
+
+	b.notify(changes)
+}
+
+func (b *Breaker) shouldOpen() bool {
+	if b.cfg.ConsecutiveFailures > 0 && b.consecutiveFailures >= b.cfg.ConsecutiveFailures {
+		return true
+	}
+
+	if b.cfg.FailureRate > 0 && b.requests >= b.cfg.MinRequests {
+		return float64(b.failures)/float64(b.requests) >= b.cfg.FailureRate
+	}
+
+	return false
+}
+
+// currentState to move the state by the time, must be called with the lock.
+func (b *Breaker) currentState(now time.Time) State {
+	switch b.state {
+	case Closed:
+		if b.cfg.Window > 0 && now.Sub(b.windowStart) >= b.cfg.Window {
+			b.resetCounts(now)
+		}
+	case Open:
+		if now.Sub(b.openedAt) >= b.cfg.Cooldown {
+			b.setState(HalfOpen, now)
+		}
+	}
+
+	return b.state
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	b.changes = append(b.changes, [2]State{b.state, state})
+	b.state = state
+	b.halfOpenRequests = 0
+	b.halfOpenSuccesses = 0
+	b.resetCounts(now)
+
+	if state == Open {
+		b.openedAt = now
+	}
+}
+
+func (b *Breaker) resetCounts(now time.Time) {
+	b.requests = 0
+	b.failures = 0
+	b.consecutiveFailures = 0
+	b.windowStart = now
+}
+
+// takeChanges to get the state changes to notify, must be called with the lock.
+func (b *Breaker) takeChanges() [][2]State {
+	changes := b.changes
+	b.changes = nil
+
+	return changes
+}
+
+// notify to call OnStateChange without the lock.
+func (b *Breaker) notify(changes [][2]State) {
+	if b.cfg.OnStateChange == nil {
+		return
+	}
+
+	for _, change := range changes {
+		b.cfg.OnStateChange(b.key, change[0], change[1])
+	}
+}
+
+// Group is a set of circuits by the key, safe for concurrent use.
+type Group struct {
+	cfg Config
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// NewGroup to create a new set of circuits with the same configuration.
+func NewGroup(cfg *Config) *Group {
+	return &Group{
+		cfg:      *cfg,
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+// Get to get the circuit of the key, the circuit is created if not exists.
+func (g *Group) Get(key string) *Breaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	b, ok := g.breakers[key]
+	if !ok {
+		b = New(key, &g.cfg)
+		g.breakers[key] = b
+	}
+
+	return b
+}
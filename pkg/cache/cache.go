@@ -0,0 +1,303 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// heuristicStatusCodes is the status codes which are cacheable by default, by RFC 9110 section 15.1.
+var heuristicStatusCodes = map[int]bool{
+	fasthttp.StatusOK:                   true,
+	fasthttp.StatusNonAuthoritativeInfo: true,
+	fasthttp.StatusNoContent:            true,
+	fasthttp.StatusMultipleChoices:      true,
+	fasthttp.StatusMovedPermanently:     true,
+	fasthttp.StatusPermanentRedirect:    true,
+	fasthttp.StatusNotFound:             true,
+	fasthttp.StatusMethodNotAllowed:     true,
+	fasthttp.StatusGone:                 true,
+	fasthttp.StatusRequestURITooLong:    true,
+	fasthttp.StatusNotImplemented:       true,
+}
+
+// Config is an HTTP cache configuration.
+type Config struct {
+	// Store to store the responses, the default value is an LRU store with 1000 responses.
+	Store Store
+	// Shared to behave as a shared cache, the private responses are not stored
+	// and s-maxage is used. By default, the cache is a private cache.
+	Shared bool
+
+	// StaleWhileRevalidate and StaleIfError is the default stale window of RFC 5861,
+	// if the response has no stale-while-revalidate or stale-if-error directive.
+	// The default value is 0, only the directives of the response are used.
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+}
+
+// Cache is an HTTP cache with RFC 9111 semantics for the GET method, safe for concurrent use.
+type Cache struct {
+	store                Store
+	shared               bool
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+
+	mu           sync.Mutex
+	revalidating map[string]bool
+}
+
+// New to create a new HTTP cache.
+func New(cfg *Config) *Cache {
+	store := cfg.Store
+	if store == nil {
+		store = NewLRU(1000)
+	}
+
+	return &Cache{
+		store:                store,
+		shared:               cfg.Shared,
+		staleWhileRevalidate: cfg.StaleWhileRevalidate,
+		staleIfError:         cfg.StaleIfError,
+		revalidating:         make(map[string]bool),
+	}
+}
+
+// Key to get the cache key of the request, the method and the URL.
+// Every URL has one stored response, the credentials and the Vary header values
+// of the request are checked like a secondary key, see Entry.Credentials.
+func Key(req *fasthttp.Request) string {
+	return key(string(req.Header.Method()), req.URI().String())
+}
+
+func key(method, uri string) string {
+	return method + " " + uri
+}
+
+// credentials to get the hash of the Authorization header of the request,
+// the raw credentials are never stored.
+func credentials(req *fasthttp.Request) string {
+	v := req.Header.Peek(fasthttp.HeaderAuthorization)
+	if len(v) == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256(v)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup to get the stored response of the request, and check if it is fresh.
+// A fresh response can be served without calling the HTTP,
+// a response which is not fresh must be revalidated.
+func (c *Cache) Lookup(req *fasthttp.Request, now time.Time) (*Entry, bool) {
+	if !req.Header.IsGet() {
+		return nil, false
+	}
+
+	d := requestDirectives(req)
+	if d.has("no-store") {
+		return nil, false
+	}
+
+	entry, ok := c.store.Get(Key(req))
+	if !ok || !entry.matchVary(req) {
+		return nil, false
+	}
+
+	fresh := entry.Fresh(now, c.shared)
+	if d.has("no-cache") {
+		fresh = false
+	}
+
+	if maxAge, ok := d.seconds("max-age"); ok && entry.Age(now) > maxAge {
+		fresh = false
+	}
+
+	return entry, fresh
+}
+
+// StaleWhileRevalidate to check if the stale response can be served
+// while it is revalidated in the background, by RFC 5861 section 3.
+func (c *Cache) StaleWhileRevalidate(entry *Entry, now time.Time) bool {
+	d := entry.directives()
+	window, ok := d.seconds("stale-while-revalidate")
+	if !ok {
+		window = c.staleWhileRevalidate
+	}
+
+	return c.servableStale(entry, d, window, now)
+}
+
+// StaleIfError to check if the stale response can be served
+// if the HTTP call fails or responds with a 5xx status code, by RFC 5861 section 4.
+// The stale-if-error directive of the request has precedence.
+func (c *Cache) StaleIfError(req *fasthttp.Request, entry *Entry, now time.Time) bool {
+	d := entry.directives()
+	window, ok := requestDirectives(req).seconds("stale-if-error")
+	if !ok {
+		window, ok = d.seconds("stale-if-error")
+	}
+	if !ok {
+		window = c.staleIfError
+	}
+
+	return c.servableStale(entry, d, window, now)
+}
+
+// servableStale to check if the stale response is in the stale window,
+// and serving it stale is not prohibited.
+func (c *Cache) servableStale(entry *Entry, d directives, window time.Duration, now time.Time) bool {
+	if window <= 0 || d.has("must-revalidate") || d.has("no-cache") || c.shared && d.has("proxy-revalidate") {
+		return false
+	}
+
+	return entry.Age(now)-entry.FreshnessLifetime(c.shared) <= window
+}
+
+// BeginRevalidate to mark the stored response is being revalidated in the background,
+// it returns false if the stored response is already being revalidated.
+// EndRevalidate must be called after the revalidation.
+func (c *Cache) BeginRevalidate(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.revalidating[key] {
+		return false
+	}
+	c.revalidating[key] = true
+
+	return true
+}
+
+// EndRevalidate to mark the background revalidation is done.
+func (c *Cache) EndRevalidate(key string) {
+	c.mu.Lock()
+	delete(c.revalidating, key)
+	c.mu.Unlock()
+}
+
+// Conditional to set the validators of the stored response to the request,
+// If-None-Match for the ETag and If-Modified-Since for the Last-Modified.
+// It returns false if the stored response has no validator.
+func (c *Cache) Conditional(req *fasthttp.Request, entry *Entry) bool {
+	etag := entry.Get("ETag")
+	lastModified := entry.Get("Last-Modified")
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	return etag != "" || lastModified != ""
+}
+
+// Store to store the response of the request if it is storable.
+// The response of an unsafe method invalidates the stored responses of the URL.
+func (c *Cache) Store(req *fasthttp.Request, resp *fasthttp.Response, requestTime, responseTime time.Time) {
+	if !req.Header.IsGet() {
+		c.invalidate(req, resp)
+
+		return
+	}
+
+	entry := newEntry(Key(req), req, resp, requestTime, responseTime)
+	if c.storable(req, entry) {
+		c.store.Set(entry.Key, entry)
+	}
+}
+
+// Revalidated to update the stored response with the 304 response of the revalidation,
+// the updated response is stored and returned.
+func (c *Cache) Revalidated(entry *Entry, resp *fasthttp.Response, requestTime, responseTime time.Time) *Entry {
+	updated := entry.update(resp, requestTime, responseTime)
+	if !updated.directives().has("no-store") {
+		c.store.Set(updated.Key, updated)
+	} else {
+		c.store.Delete(updated.Key)
+	}
+
+	return updated
+}
+
+// storable to check if the response can be stored, by RFC 9111 section 3.
+func (c *Cache) storable(req *fasthttp.Request, entry *Entry) bool {
+	if entry.StatusCode == fasthttp.StatusPartialContent || entry.StatusCode < 200 ||
+		entry.StatusCode == fasthttp.StatusNotModified {
+		return false
+	}
+
+	rd := requestDirectives(req)
+	d := entry.directives()
+	if rd.has("no-store") || d.has("no-store") {
+		return false
+	}
+
+	for _, name := range entry.varyNames() {
+		if name == "*" {
+			return false
+		}
+	}
+
+	if c.shared {
+		if d.has("private") {
+			return false
+		}
+
+		if len(req.Header.Peek(fasthttp.HeaderAuthorization)) > 0 &&
+			!d.has("public") && !d.has("s-maxage") && !d.has("must-revalidate") {
+			return false
+		}
+	}
+
+	// The response must be fresh for a while, can be revalidated or can be served stale.
+	return d.has("no-cache") || entry.FreshnessLifetime(c.shared) > 0 ||
+		entry.Get("ETag") != "" || entry.Get("Last-Modified") != "" ||
+		heuristicStatusCodes[entry.StatusCode] && (d.has("stale-while-revalidate") || d.has("stale-if-error") ||
+			c.staleWhileRevalidate > 0 || c.staleIfError > 0)
+}
+
+// invalidate to delete the stored responses of the URL, the Location and the Content-Location
+// after an unsafe method succeeded, by RFC 9111 section 4.4, for any credentials.
+func (c *Cache) invalidate(req *fasthttp.Request, resp *fasthttp.Response) {
+	if resp.StatusCode() < 200 || resp.StatusCode() >= 400 {
+		return
+	}
+
+	target := req.URI().String()
+	c.store.Delete(key(fasthttp.MethodGet, target))
+
+	base, e := url.Parse(target)
+	if e != nil {
+		return
+	}
+
+	for _, name := range []string{fasthttp.HeaderLocation, fasthttp.HeaderContentLocation} {
+		v := string(resp.Header.Peek(name))
+		if v == "" {
+			continue
+		}
+
+		// Only the same origin is invalidated.
+		if u, e := base.Parse(v); e == nil && strings.EqualFold(u.Host, base.Host) && u.Scheme == base.Scheme {
+			c.store.Delete(key(fasthttp.MethodGet, u.String()))
+		}
+	}
+}
+
+func requestDirectives(req *fasthttp.Request) directives {
+	d := parseCacheControl(string(req.Header.Peek(fasthttp.HeaderCacheControl)))
+	if len(d) == 0 && string(req.Header.Peek(fasthttp.HeaderPragma)) == "no-cache" {
+		d["no-cache"] = ""
+	}
+
+	return d
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// directives is the parsed Cache-Control header, the directive names are lowercase.
+type directives map[string]string
+
+func parseCacheControl(values ...string) directives {
+	d := make(directives)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			name, arg := part, ""
+			if i := strings.Index(part, "="); i >= 0 {
+				name, arg = part[:i], strings.Trim(strings.TrimSpace(part[i+1:]), `"`)
+			}
+
+			name = strings.ToLower(strings.TrimSpace(name))
+			// The first directive is used if it is duplicated.
+			if _, ok := d[name]; !ok {
+				d[name] = arg
+			}
+		}
+	}
+
+	return d
+}
+
+func (d directives) has(name string) bool {
+	_, ok := d[name]
+
+	return ok
+}
+
+// seconds to get the delta-seconds argument of the directive.
+func (d directives) seconds(name string) (time.Duration, bool) {
+	arg, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+
+	n, e := strconv.ParseInt(arg, 10, 64)
+	if e != nil || n < 0 {
+		// An invalid value is treated as stale, by RFC 9111 section 1.2.2.
+		return 0, true
+	}
+
+	return time.Duration(n) * time.Second, true
+}
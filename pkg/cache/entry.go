@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Field is a response header field.
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Entry is a stored response.
+type Entry struct {
+	Key        string  `json:"key"`
+	StatusCode int     `json:"status_code"`
+	Header     []Field `json:"header"`
+	Body       []byte  `json:"body"`
+	// Vary is the request header values of the header names in the Vary response header.
+	Vary map[string]string `json:"vary,omitempty"`
+	// Credentials is the hash of the Authorization header of the request,
+	// the stored response is only served for the same credentials like a Vary header.
+	// The response for other credentials replaces it.
+	Credentials string `json:"credentials,omitempty"`
+	// RequestTime and ResponseTime is when the request is sent and the response is received,
+	// to calculate the age of the response.
+	RequestTime  time.Time `json:"request_time"`
+	ResponseTime time.Time `json:"response_time"`
+}
+
+// hopByHopHeaders is not stored, by RFC 9111 section 3.1.
+var hopByHopHeaders = map[string]bool{
+	"connection":          true,
+	"keep-alive":          true,
+	"proxy-connection":    true,
+	"proxy-authenticate":  true,
+	"proxy-authorization": true,
+	"te":                  true,
+	"trailer":             true,
+	"transfer-encoding":   true,
+	"upgrade":             true,
+}
+
+// newEntry to create a stored response from the response.
+func newEntry(key string, req *fasthttp.Request, resp *fasthttp.Response, requestTime, responseTime time.Time) *Entry {
+	entry := &Entry{
+		Key:          key,
+		StatusCode:   resp.StatusCode(),
+		Body:         append([]byte(nil), resp.Body()...),
+		Credentials:  credentials(req),
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+
+	resp.Header.VisitAll(func(key, value []byte) {
+		if !hopByHopHeaders[strings.ToLower(string(key))] {
+			entry.Header = append(entry.Header, Field{Name: string(key), Value: string(value)})
+		}
+	})
+
+	for _, name := range entry.varyNames() {
+		if entry.Vary == nil {
+			entry.Vary = make(map[string]string)
+		}
+		entry.Vary[name] = string(req.Header.Peek(name))
+	}
+
+	return entry
+}
+
+// Get to get the first header value of the name, case-insensitively.
+func (e *Entry) Get(name string) string {
+	for _, field := range e.Header {
+		if strings.EqualFold(field.Name, name) {
+			return field.Value
+		}
+	}
+
+	return ""
+}
+
+func (e *Entry) values(name string) []string {
+	values := make([]string, 0)
+	for _, field := range e.Header {
+		if strings.EqualFold(field.Name, name) {
+			values = append(values, field.Value)
+		}
+	}
+
+	return values
+}
+
+func (e *Entry) directives() directives {
+	return parseCacheControl(e.values("Cache-Control")...)
+}
+
+// varyNames to get the lowercase header names in the Vary header.
+func (e *Entry) varyNames() []string {
+	names := make([]string, 0)
+	for _, value := range e.values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// matchVary to check if the request has the same credentials and the same header values of the Vary header.
+func (e *Entry) matchVary(req *fasthttp.Request) bool {
+	if credentials(req) != e.Credentials {
+		return false
+	}
+
+	for name, value := range e.Vary {
+		if string(req.Header.Peek(name)) != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *Entry) date() (time.Time, bool) {
+	date, err := fasthttp.ParseHTTPDate([]byte(e.Get("Date")))
+
+	return date, err == nil
+}
+
+// Age to get the current age of the response, by RFC 9111 section 4.2.3.
+func (e *Entry) Age(now time.Time) time.Duration {
+	var apparentAge time.Duration
+	if date, ok := e.date(); ok && e.ResponseTime.After(date) {
+		apparentAge = e.ResponseTime.Sub(date)
+	}
+
+	var ageValue time.Duration
+	if seconds, err := strconv.ParseInt(e.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	initialAge := apparentAge
+	if correctedAge > initialAge {
+		initialAge = correctedAge
+	}
+
+	return initialAge + now.Sub(e.ResponseTime)
+}
+
+// FreshnessLifetime to get how long the response is fresh, by RFC 9111 section 4.2.1.
+// The heuristic freshness is 10% of the time since the Last-Modified date.
+func (e *Entry) FreshnessLifetime(shared bool) time.Duration {
+	d := e.directives()
+
+	if shared {
+		if lifetime, ok := d.seconds("s-maxage"); ok {
+			return lifetime
+		}
+	}
+
+	if lifetime, ok := d.seconds("max-age"); ok {
+		return lifetime
+	}
+
+	date, hasDate := e.date()
+	if !hasDate {
+		date = e.ResponseTime
+	}
+
+	if v := e.Get("Expires"); v != "" {
+		expires, err := fasthttp.ParseHTTPDate([]byte(v))
+		if err != nil || !expires.After(date) {
+			return 0
+		}
+
+		return expires.Sub(date)
+	}
+
+	if !heuristicStatusCodes[e.StatusCode] && !d.has("public") {
+		return 0
+	}
+
+	if lastModified, err := fasthttp.ParseHTTPDate([]byte(e.Get("Last-Modified"))); err == nil && date.After(lastModified) {
+		return date.Sub(lastModified) / 10
+	}
+
+	return 0
+}
+
+// Fresh to check if the response can be served without revalidation.
+func (e *Entry) Fresh(now time.Time, shared bool) bool {
+	if e.directives().has("no-cache") {
+		return false
+	}
+
+	return e.FreshnessLifetime(shared) > e.Age(now)
+}
+
+// WriteTo to write the stored response to the response, with the Age header.
+func (e *Entry) WriteTo(resp *fasthttp.Response, now time.Time) {
+	resp.Reset()
+	resp.SetStatusCode(e.StatusCode)
+
+	for _, field := range e.Header {
+		switch strings.ToLower(field.Name) {
+		case "content-length", "age":
+		case "content-type", "server", "set-cookie":
+			resp.Header.Set(field.Name, field.Value)
+		default:
+			resp.Header.Add(field.Name, field.Value)
+		}
+	}
+
+	resp.Header.Set("Age", strconv.FormatInt(int64(e.Age(now)/time.Second), 10))
+	resp.SetBody(e.Body)
+}
+
+// update to update the stored header with the header of a 304 response,
+// by RFC 9111 section 3.2. The Content-Length header is not updated.
+func (e *Entry) update(resp *fasthttp.Response, requestTime, responseTime time.Time) *Entry {
+	updated := *e
+	updated.RequestTime = requestTime
+	updated.ResponseTime = responseTime
+
+	replaced := make(map[string]bool)
+	resp.Header.VisitAll(func(key, value []byte) {
+		name := strings.ToLower(string(key))
+		if hopByHopHeaders[name] || name == "content-length" {
+			return
+		}
+
+		replaced[name] = true
+	})
+
+	header := make([]Field, 0, len(e.Header))
+	for _, field := range e.Header {
+		if !replaced[strings.ToLower(field.Name)] {
+			header = append(header, field)
+		}
+	}
+
+	resp.Header.VisitAll(func(key, value []byte) {
+		if replaced[strings.ToLower(string(key))] {
+			header = append(header, Field{Name: string(key), Value: string(value)})
+		}
+	})
+	updated.Header = header
+
+	return &updated
+}
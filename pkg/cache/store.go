@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store stores the responses by the cache key, it must be safe for concurrent use.
+type Store interface {
+	Get(key string) (*Entry, bool)
+	Set(key string, entry *Entry)
+	Delete(key string)
+}
+
+// LRU is an in-memory store, the least recently used response is removed
+// if the max entries is reached.
+type LRU struct {
+	max int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// NewLRU to create a new in-memory store with the max entries.
+func NewLRU(maxEntries int) *LRU {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+
+	return &LRU{
+		max:   maxEntries,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get to get the stored response.
+func (s *LRU) Get(key string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	s.ll.MoveToFront(el)
+
+	return el.Value.(*Entry), true
+}
+
+// Set to store the response.
+func (s *LRU) Set(key string, entry *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		el.Value = entry
+		s.ll.MoveToFront(el)
+
+		return
+	}
+
+	s.items[key] = s.ll.PushFront(entry)
+	if s.ll.Len() > s.max {
+		oldest := s.ll.Back()
+		s.ll.Remove(oldest)
+		delete(s.items, oldest.Value.(*Entry).Key)
+	}
+}
+
+// Delete to remove the stored response.
+func (s *LRU) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.ll.Remove(el)
+		delete(s.items, key)
+	}
+}
+
+// Len to get how much response is stored.
+func (s *LRU) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ll.Len()
+}
+
+// Disk is an on-disk store, every response is a JSON file in the directory.
+type Disk struct {
+	dir string
+}
+
+// NewDisk to create a new on-disk store, the directory is created if not exists.
+func NewDisk(dir string) (*Disk, error) {
+	if e := os.MkdirAll(dir, 0700); e != nil {
+		return nil, e
+	}
+
+	return &Disk{dir: dir}, nil
+}
+
+func (s *Disk) filename(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get to read the stored response, a broken file is ignored.
+func (s *Disk) Get(key string) (*Entry, bool) {
+	b, e := ioutil.ReadFile(s.filename(key))
+	if e != nil {
+		return nil, false
+	}
+
+	var entry Entry
+	if e := json.Unmarshal(b, &entry); e != nil || entry.Key != key {
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+// Set to write the stored response, the file is replaced atomically.
+// The write error is ignored, the response is just not cached.
+func (s *Disk) Set(key string, entry *Entry) {
+	b, e := json.Marshal(entry)
+	if e != nil {
+		return
+	}
+
+	f, e := ioutil.TempFile(s.dir, "entry.*.tmp")
+	if e != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+
+	if _, e := f.Write(b); e != nil {
+		f.Close()
+
+		return
+	}
+
+	if e := f.Close(); e != nil {
+		return
+	}
+
+	os.Rename(f.Name(), s.filename(key))
+}
+
+// Delete to remove the stored response.
+func (s *Disk) Delete(key string) {
+	os.Remove(s.filename(key))
+}
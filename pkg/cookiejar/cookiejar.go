@@ -0,0 +1,424 @@
+package cookiejar
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PublicSuffixList is a list of public suffixes, like "com" or "co.id".
+// A cookie cannot be set for a public suffix.
+type PublicSuffixList interface {
+	PublicSuffix(domain string) string
+}
+
+// Config is a cookie jar configuration.
+type Config struct {
+	// PublicSuffixList to reject the cookies for a public suffix.
+	// If nil, the domain attribute without a dot is rejected, like "com".
+	PublicSuffixList PublicSuffixList
+}
+
+// Cookie is a stored cookie by RFC 6265 section 5.3.
+type Cookie struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Domain string `json:"domain"`
+	Path   string `json:"path"`
+	// Expires is the expiry time of the cookie, zero means a session cookie.
+	Expires  time.Time `json:"expires"`
+	Secure   bool      `json:"secure"`
+	HTTPOnly bool      `json:"http_only"`
+	// HostOnly means the cookie is only sent to the exact host,
+	// the cookie has no domain attribute.
+	HostOnly   bool      `json:"host_only"`
+	Created    time.Time `json:"created"`
+	LastAccess time.Time `json:"last_access"`
+}
+
+func (c *Cookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c *Cookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !now.Before(c.Expires)
+}
+
+// Jar stores the received cookies and gets the cookies to send by the URL,
+// by the domain, path and expiry rules of RFC 6265. Jar is safe for concurrent use.
+type Jar struct {
+	psl PublicSuffixList
+
+	mu      sync.Mutex
+	cookies map[string]*Cookie
+}
+
+// New to create a new empty cookie jar, cfg can be nil.
+func New(cfg *Config) *Jar {
+	j := &Jar{cookies: make(map[string]*Cookie)}
+	if cfg != nil {
+		j.psl = cfg.PublicSuffixList
+	}
+
+	return j
+}
+
+// SetCookies to store the cookies of the Set-Cookie header values received from the URL.
+// A cookie with an invalid domain is ignored,
+// an expired cookie deletes the stored cookie with the same name, domain and path.
+func (j *Jar) SetCookies(u *url.URL, setCookies []string) {
+	host := canonicalHost(u.Host)
+	if host == "" {
+		return
+	}
+
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, raw := range setCookies {
+		c, ok := j.parse(raw, host, u, now)
+		if !ok {
+			continue
+		}
+
+		key := c.key()
+		if old, ok := j.cookies[key]; ok {
+			c.Created = old.Created
+		}
+
+		if c.expired(now) {
+			delete(j.cookies, key)
+
+			continue
+		}
+
+		j.cookies[key] = c
+	}
+}
+
+// Cookies to get the cookies to send to the URL,
+// the cookies with longer paths are listed first.
+func (j *Jar) Cookies(u *url.URL) []Cookie {
+	host := canonicalHost(u.Host)
+	if host == "" {
+		return nil
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	secure := u.Scheme == "https" || u.Scheme == "wss"
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	cookies := make([]*Cookie, 0)
+	for key, c := range j.cookies {
+		if c.expired(now) {
+			delete(j.cookies, key)
+
+			continue
+		}
+
+		if c.HostOnly && c.Domain != host || !c.HostOnly && !domainMatch(host, c.Domain) {
+			continue
+		}
+
+		if !pathMatch(path, c.Path) || c.Secure && !secure {
+			continue
+		}
+
+		c.LastAccess = now
+		cookies = append(cookies, c)
+	}
+
+	// By RFC 6265 section 5.4, longer paths first, then earlier creation times first.
+	sort.Slice(cookies, func(a, b int) bool {
+		if len(cookies[a].Path) != len(cookies[b].Path) {
+			return len(cookies[a].Path) > len(cookies[b].Path)
+		}
+
+		if !cookies[a].Created.Equal(cookies[b].Created) {
+			return cookies[a].Created.Before(cookies[b].Created)
+		}
+
+		return cookies[a].Name < cookies[b].Name
+	})
+
+	list := make([]Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		list = append(list, *c)
+	}
+
+	return list
+}
+
+// All to get every unexpired cookie in the jar.
+func (j *Jar) All() []Cookie {
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	list := make([]Cookie, 0, len(j.cookies))
+	for key, c := range j.cookies {
+		if c.expired(now) {
+			delete(j.cookies, key)
+
+			continue
+		}
+
+		list = append(list, *c)
+	}
+
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].key() < list[b].key()
+	})
+
+	return list
+}
+
+// Clear to remove every cookie in the jar.
+func (j *Jar) Clear() {
+	j.mu.Lock()
+	j.cookies = make(map[string]*Cookie)
+	j.mu.Unlock()
+}
+
+// Save to write every unexpired cookie to a JSON file,
+// including the session cookies to resume a login session later.
+// The file is replaced atomically and only readable by the owner.
+func (j *Jar) Save(filename string) error {
+	b, e := json.MarshalIndent(j.All(), "", "  ")
+	if e != nil {
+		return e
+	}
+
+	f, e := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if e != nil {
+		return e
+	}
+	defer os.Remove(f.Name())
+
+	if _, e := f.Write(b); e != nil {
+		f.Close()
+
+		return e
+	}
+
+	if e := f.Close(); e != nil {
+		return e
+	}
+
+	if e := os.Chmod(f.Name(), 0600); e != nil {
+		return e
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+// Load to read the cookies from a JSON file written by Save,
+// the expired cookies are skipped and the stored cookies with the same name, domain and path are replaced.
+func (j *Jar) Load(filename string) error {
+	b, e := ioutil.ReadFile(filename)
+	if e != nil {
+		return e
+	}
+
+	var list []Cookie
+	if e := json.Unmarshal(b, &list); e != nil {
+		return e
+	}
+
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i := range list {
+		c := list[i]
+		if c.Name == "" || c.Domain == "" || c.expired(now) {
+			continue
+		}
+
+		if c.Path == "" {
+			c.Path = "/"
+		}
+
+		j.cookies[c.key()] = &c
+	}
+
+	return nil
+}
+
+// parse to parse a Set-Cookie header value by RFC 6265 section 5.2 and 5.3,
+// must be called with the lock.
+func (j *Jar) parse(raw, host string, u *url.URL, now time.Time) (*Cookie, bool) {
+	parts := strings.Split(raw, ";")
+
+	name, value, ok := splitPair(parts[0])
+	if !ok || name == "" {
+		return nil, false
+	}
+
+	c := &Cookie{
+		Name:       name,
+		Value:      strings.Trim(value, `"`),
+		Created:    now,
+		LastAccess: now,
+	}
+
+	var (
+		domain  string
+		path    string
+		maxAge  *time.Time
+		expires *time.Time
+	)
+
+	for _, part := range parts[1:] {
+		attr, val, _ := splitPair(part)
+
+		switch strings.ToLower(attr) {
+		case "domain":
+			// The leading dot is ignored.
+			domain = strings.ToLower(strings.TrimPrefix(val, "."))
+		case "path":
+			path = val
+		case "max-age":
+			seconds, e := strconv.ParseInt(val, 10, 64)
+			if e != nil {
+				continue
+			}
+
+			t := now.Add(time.Duration(seconds) * time.Second)
+			if seconds <= 0 {
+				t = time.Unix(0, 0)
+			}
+			maxAge = &t
+		case "expires":
+			if t, ok := parseExpires(val); ok {
+				expires = &t
+			}
+		case "secure":
+			c.Secure = true
+		case "httponly":
+			c.HTTPOnly = true
+		}
+	}
+
+	// Max-Age has precedence over Expires.
+	if maxAge != nil {
+		c.Expires = *maxAge
+	} else if expires != nil {
+		c.Expires = *expires
+	}
+
+	if domain == "" || domain == host {
+		c.Domain = host
+		c.HostOnly = domain == ""
+	} else {
+		if !domainMatch(host, domain) || j.isPublicSuffix(domain) {
+			return nil, false
+		}
+
+		c.Domain = domain
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = defaultPath(u.EscapedPath())
+	}
+	c.Path = path
+
+	return c, true
+}
+
+func (j *Jar) isPublicSuffix(domain string) bool {
+	if j.psl != nil {
+		return j.psl.PublicSuffix(domain) == domain
+	}
+
+	return !strings.Contains(domain, ".")
+}
+
+func splitPair(s string) (string, string, bool) {
+	s = strings.TrimSpace(s)
+
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return s, "", false
+	}
+
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), true
+}
+
+var expiresLayouts = []string{
+	time.RFC1123,
+	"Mon, 02-Jan-2006 15:04:05 MST",
+	"Monday, 02-Jan-06 15:04:05 MST",
+	time.ANSIC,
+}
+
+func parseExpires(val string) (time.Time, bool) {
+	for _, layout := range expiresLayouts {
+		if t, e := time.Parse(layout, val); e == nil {
+			return t.UTC(), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// canonicalHost to lowercase the host without the port.
+func canonicalHost(host string) string {
+	if h, _, e := net.SplitHostPort(host); e == nil {
+		host = h
+	}
+
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// domainMatch by RFC 6265 section 5.1.3.
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+
+	return net.ParseIP(host) == nil &&
+		strings.HasSuffix(host, domain) &&
+		host[len(host)-len(domain)-1] == '.'
+}
+
+// defaultPath by RFC 6265 section 5.1.4.
+func defaultPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if !strings.HasPrefix(path, "/") || i == 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
+
+// pathMatch by RFC 6265 section 5.1.4.
+func pathMatch(path, cookiePath string) bool {
+	if path == cookiePath {
+		return true
+	}
+
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+
+	return strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
@@ -2,6 +2,7 @@ package merging
 
 import (
 	"encoding/json"
+	"errors"
 	"regexp"
 	"strconv"
 
@@ -26,21 +27,38 @@ func New() *Config {
 }
 
 // Merge to merging all the response body.
-func (m *Config) Merge(blacklist []string, b []byte) {
+// An error is returned if the response body is not a JSON object.
+func (m *Config) Merge(blacklist []string, b []byte) error {
 	c := make(map[string]interface{})
-	json.Unmarshal(b, &c)
+	if e := json.Unmarshal(b, &c); e != nil {
+		return e
+	}
 
 	for field, row := range c {
 		if find(blacklist, field) == false {
 			m.data[field] = row
 		}
 	}
+
+	return nil
 }
 
 // MergeFromWhitelist to merge response body from whitelist field.
-func (m *Config) MergeFromWhitelist(whitelist []string, b []byte) {
-	v, _ := fastjson.ParseBytes(b)
+// An error is returned if the response body is not a JSON object.
+func (m *Config) MergeFromWhitelist(whitelist []string, b []byte) error {
+	v, e := fastjson.ParseBytes(b)
+	if e != nil {
+		return e
+	}
+	if v.Type() != fastjson.TypeObject {
+		return errors.New("response body is not a JSON object")
+	}
+
 	for _, field := range whitelist {
+		if !v.Exists(field) {
+			continue
+		}
+
 		fieldType := v.Get(field).Type()
 
 		switch fieldType {
@@ -85,6 +103,8 @@ func (m *Config) MergeFromWhitelist(whitelist []string, b []byte) {
 			m.data[field] = nil
 		}
 	}
+
+	return nil
 }
 
 // Get to get response body byte
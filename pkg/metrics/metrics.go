@@ -0,0 +1,71 @@
+package metrics
+
+import "time"
+
+// Collector collects the metrics of the HTTP calls, like the Prometheus exporter.
+// Collector must be safe for concurrent use.
+type Collector interface {
+	// ObserveRequest is called once for every upstream HTTP call after it is done,
+	// and for every URL served by the cache or by a coalesced HTTP call, see Request.Source.
+	ObserveRequest(r Request)
+	// ObserveRetry is called before waiting for the next HTTP attempt.
+	ObserveRetry(r Retry)
+	// ObserveMerge is called after the response bodies are merged.
+	ObserveMerge(m Merge)
+}
+
+// Retry reasons.
+const (
+	ReasonTimeout = "timeout"
+	ReasonError   = "error"
+	ReasonStatus  = "status"
+)
+
+// Sources of the response.
+const (
+	// SourceUpstream is a response from the host.
+	SourceUpstream = "upstream"
+	// SourceCache is a response served by the cache without calling the host.
+	SourceCache = "cache"
+	// SourceCoalesced is a response shared with the concurrent identical HTTP calls,
+	// the shared upstream HTTP call is observed once with SourceUpstream.
+	SourceCoalesced = "coalesced"
+)
+
+// Request is the metrics of calling one URL.
+type Request struct {
+	Method string
+	Host   string
+	// Source is where the response is from, like SourceUpstream.
+	// Only the upstream HTTP call sends the request, so it is the only one with BytesSent.
+	Source string
+	// StatusCode is 0 if the HTTP call fails without a response.
+	StatusCode int
+	Duration   time.Duration
+	// Attempts is how much the HTTP was called, 0 if the response is served by the cache.
+	Attempts int
+	// Timeout reports if the HTTP call fails by the timeout.
+	Timeout       bool
+	BytesSent     int
+	BytesReceived int
+	Err           error
+}
+
+// Retry is the metrics of a failed HTTP attempt which is retried.
+type Retry struct {
+	Method string
+	Host   string
+	// Attempt is the failed HTTP attempt, starting from 1.
+	Attempt int
+	// Reason is why the HTTP attempt is retried, like ReasonTimeout.
+	Reason     string
+	StatusCode int
+	Wait       time.Duration
+}
+
+// Merge is the metrics of merging the response bodies.
+type Merge struct {
+	Sources  int
+	Duration time.Duration
+	Err      error
+}
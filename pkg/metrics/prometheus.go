@@ -0,0 +1,245 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultNamespace is the prefix of the metric names.
+const DefaultNamespace = "panggilhttp"
+
+// DefaultBuckets is the upper bounds of the duration histograms, in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PrometheusConfig is the Prometheus exporter configuration.
+type PrometheusConfig struct {
+	// Namespace is the prefix of the metric names, the default value is DefaultNamespace.
+	Namespace string
+	// Buckets is the upper bounds of the duration histograms, the default value is DefaultBuckets.
+	Buckets []float64
+}
+
+// Prometheus is a Collector to expose the metrics in the Prometheus text format,
+// without any dependency on the Prometheus client library.
+// Prometheus is safe for concurrent use.
+type Prometheus struct {
+	namespace string
+	buckets   []float64
+
+	mu            sync.Mutex
+	requests      map[string]float64 // method, host, status
+	served        map[string]float64 // method, host, source
+	timeouts      map[string]float64 // method, host
+	retries       map[string]float64 // method, host, reason
+	bytesSent     map[string]float64 // method, host
+	bytesReceived map[string]float64 // method, host
+	durations     map[string]*histogram
+	merges        *histogram
+	mergeErrors   float64
+}
+
+type histogram struct {
+	counts []uint64 // cumulative count of every bucket
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheus to create a new Prometheus exporter, cfg can be nil.
+func NewPrometheus(cfg *PrometheusConfig) *Prometheus {
+	if cfg == nil {
+		cfg = &PrometheusConfig{}
+	}
+
+	namespace := DefaultNamespace
+	if cfg.Namespace != "" {
+		namespace = cfg.Namespace
+	}
+
+	buckets := DefaultBuckets
+	if len(cfg.Buckets) > 0 {
+		buckets = append([]float64(nil), cfg.Buckets...)
+		sort.Float64s(buckets)
+	}
+
+	return &Prometheus{
+		namespace:     namespace,
+		buckets:       buckets,
+		requests:      make(map[string]float64),
+		served:        make(map[string]float64),
+		timeouts:      make(map[string]float64),
+		retries:       make(map[string]float64),
+		bytesSent:     make(map[string]float64),
+		bytesReceived: make(map[string]float64),
+		durations:     make(map[string]*histogram),
+		merges:        newHistogram(buckets),
+	}
+}
+
+// ObserveRequest to count the upstream HTTP call by the method, host and status code,
+// and to observe its duration, timeout and bytes.
+// The response served by the cache or by a coalesced HTTP call is only counted by its source.
+func (p *Prometheus) ObserveRequest(r Request) {
+	status := "error"
+	if r.StatusCode > 0 {
+		status = strconv.Itoa(r.StatusCode)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if r.Source != "" && r.Source != SourceUpstream {
+		p.served[labels("method", r.Method, "host", r.Host, "source", r.Source)]++
+
+		return
+	}
+
+	host := labels("method", r.Method, "host", r.Host)
+	p.requests[labels("method", r.Method, "host", r.Host, "status", status)]++
+	p.bytesSent[host] += float64(r.BytesSent)
+	p.bytesReceived[host] += float64(r.BytesReceived)
+	if r.Timeout {
+		p.timeouts[host]++
+	}
+
+	h, ok := p.durations[host]
+	if !ok {
+		h = newHistogram(p.buckets)
+		p.durations[host] = h
+	}
+	h.observe(p.buckets, r.Duration.Seconds())
+}
+
+// ObserveRetry to count the retried HTTP attempt by the method, host and reason.
+func (p *Prometheus) ObserveRetry(r Retry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.retries[labels("method", r.Method, "host", r.Host, "reason", r.Reason)]++
+}
+
+// ObserveMerge to observe the duration of merging the response bodies.
+func (p *Prometheus) ObserveMerge(m Merge) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.merges.observe(p.buckets, m.Duration.Seconds())
+	if m.Err != nil {
+		p.mergeErrors++
+	}
+}
+
+// WriteTo to write the metrics in the Prometheus text format.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	p.mu.Lock()
+	p.writeCounter(&buf, "requests_total", "Total upstream HTTP calls by the method, host and status code.", p.requests)
+	p.writeCounter(&buf, "served_total", "Total HTTP calls served by the cache or by a coalesced HTTP call, by the method, host and source.", p.served)
+	p.writeHistogram(&buf, "request_duration_seconds", "Duration of the HTTP calls in seconds, including the retries.", p.durations)
+	p.writeCounter(&buf, "retries_total", "Total retried HTTP attempts by the method, host and reason.", p.retries)
+	p.writeCounter(&buf, "timeouts_total", "Total HTTP calls failed by the timeout.", p.timeouts)
+	p.writeCounter(&buf, "request_bytes_total", "Total bytes of the request bodies.", p.bytesSent)
+	p.writeCounter(&buf, "response_bytes_total", "Total bytes of the response bodies.", p.bytesReceived)
+	p.writeHistogram(&buf, "merge_duration_seconds", "Duration of merging the response bodies in seconds.", map[string]*histogram{"": p.merges})
+	p.writeCounter(&buf, "merge_errors_total", "Total response bodies which cannot be merged.", map[string]float64{"": p.mergeErrors})
+	p.mu.Unlock()
+
+	n, e := w.Write(buf.Bytes())
+
+	return int64(n), e
+}
+
+// ServeHTTP to serve the metrics for the Prometheus scraper.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+func (p *Prometheus) writeCounter(buf *bytes.Buffer, name, help string, values map[string]float64) {
+	name = p.namespace + "_" + name
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(buf, "%s%s %s\n", name, braces(key), formatFloat(values[key]))
+	}
+}
+
+func (p *Prometheus) writeHistogram(buf *bytes.Buffer, name, help string, values map[string]*histogram) {
+	name = p.namespace + "_" + name
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		h := values[key]
+		sep := ""
+		if key != "" {
+			sep = ","
+		}
+
+		for i, le := range p.buckets {
+			fmt.Fprintf(buf, "%s_bucket{%s%sle=\"%s\"} %d\n", name, key, sep, formatFloat(le), h.counts[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, key, sep, h.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", name, braces(key), formatFloat(h.sum))
+		fmt.Fprintf(buf, "%s_count%s %d\n", name, braces(key), h.count)
+	}
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(buckets []float64, v float64) {
+	for i, le := range buckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// labels to format the label pairs, the values are escaped by the Prometheus text format.
+func labels(pairs ...string) string {
+	s := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		s = append(s, pairs[i]+`="`+labelEscaper.Replace(pairs[i+1])+`"`)
+	}
+
+	return strings.Join(s, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+
+	return "{" + labels + "}"
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
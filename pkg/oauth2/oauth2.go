@@ -0,0 +1,232 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// AuthStyle is how the client credentials are sent to the token endpoint.
+type AuthStyle int
+
+const (
+	// AuthStyleHeader to send the client credentials with HTTP basic authentication.
+	AuthStyleHeader AuthStyle = iota
+	// AuthStyleParams to send the client credentials in the request body.
+	AuthStyleParams
+)
+
+// Config is an OAuth2 client credentials configuration.
+type Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// EndpointParams is the additional parameters to the token endpoint, like audience.
+	EndpointParams map[string]string
+	AuthStyle      AuthStyle
+
+	// ExpiryDelta to refresh the token before it is expired.
+	// The default value is 10 seconds.
+	ExpiryDelta time.Duration
+	// Timeout of the token request, the default value is 10 seconds.
+	Timeout time.Duration
+	// Client to call the token endpoint, a new client is used if nil.
+	Client *fasthttp.Client
+}
+
+// RetrieveError is returned when the token endpoint responds with an error.
+type RetrieveError struct {
+	StatusCode       int
+	ErrorCode        string
+	ErrorDescription string
+	Body             []byte
+}
+
+func (e *RetrieveError) Error() string {
+	if e.ErrorCode != "" {
+		return fmt.Sprintf("oauth2: cannot fetch token: %d %s: %s", e.StatusCode, e.ErrorCode, e.ErrorDescription)
+	}
+
+	return fmt.Sprintf("oauth2: cannot fetch token: %d", e.StatusCode)
+}
+
+// TokenSource is a client credentials token source with a token cache,
+// safe for concurrent use. Only 1 token request is made at the same time,
+// the other callers wait for the same token.
+type TokenSource struct {
+	cfg    Config
+	client *fasthttp.Client
+
+	mu      sync.Mutex
+	token   string
+	expiry  time.Time
+	refresh *refresh // the in-flight token request
+}
+
+type refresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+type tokenResponse struct {
+	AccessToken      string      `json:"access_token"`
+	TokenType        string      `json:"token_type"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	ErrorCode        string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+// New to create a new client credentials token source.
+func New(cfg *Config) *TokenSource {
+	c := *cfg
+	if c.ExpiryDelta <= 0 {
+		c.ExpiryDelta = 10 * time.Second
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+
+	client := c.Client
+	if client == nil {
+		client = &fasthttp.Client{}
+	}
+
+	return &TokenSource{
+		cfg:    c,
+		client: client,
+	}
+}
+
+// Token to get the cached access token,
+// a new token is fetched if the token is expired or about to expire.
+func (ts *TokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	if ts.token != "" && (ts.expiry.IsZero() || time.Now().Add(ts.cfg.ExpiryDelta).Before(ts.expiry)) {
+		token := ts.token
+		ts.mu.Unlock()
+
+		return token, nil
+	}
+
+	r := ts.refresh
+	if r == nil {
+		r = &refresh{done: make(chan struct{})}
+		ts.refresh = r
+
+		// The token request is not canceled by the context of the first caller,
+		// the other callers may still wait for it.
+		go ts.fetch(r)
+	}
+	ts.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.token, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// InvalidateToken to drop the cached token if it is rejected,
+// the next Token call fetches a new token.
+func (ts *TokenSource) InvalidateToken(token string) {
+	ts.mu.Lock()
+	if ts.token == token {
+		ts.token = ""
+		ts.expiry = time.Time{}
+	}
+	ts.mu.Unlock()
+}
+
+func (ts *TokenSource) fetch(r *refresh) {
+	token, expiry, e := ts.retrieve()
+
+	ts.mu.Lock()
+	if e == nil {
+		ts.token = token
+		ts.expiry = expiry
+	}
+	ts.refresh = nil
+	ts.mu.Unlock()
+
+	r.token = token
+	r.err = e
+	close(r.done)
+}
+
+// retrieve to call the token endpoint.
+func (ts *TokenSource) retrieve() (string, time.Time, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	args := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(args)
+
+	args.Set("grant_type", "client_credentials")
+	if len(ts.cfg.Scopes) > 0 {
+		args.Set("scope", strings.Join(ts.cfg.Scopes, " "))
+	}
+	for key, val := range ts.cfg.EndpointParams {
+		args.Set(key, val)
+	}
+
+	if ts.cfg.AuthStyle == AuthStyleParams {
+		args.Set("client_id", ts.cfg.ClientID)
+		args.Set("client_secret", ts.cfg.ClientSecret)
+	} else {
+		// The client credentials are URL encoded before base64 encoded, by RFC 6749 section 2.3.1.
+		credentials := url.QueryEscape(ts.cfg.ClientID) + ":" + url.QueryEscape(ts.cfg.ClientSecret)
+		req.Header.Set(fasthttp.HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	req.SetRequestURI(ts.cfg.TokenURL)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/x-www-form-urlencoded")
+	req.Header.Set(fasthttp.HeaderAccept, "application/json")
+	req.SetBody(args.QueryString())
+
+	if e := ts.client.DoTimeout(req, resp, ts.cfg.Timeout); e != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2: cannot fetch token: %w", e)
+	}
+
+	var body tokenResponse
+	jsonErr := json.Unmarshal(resp.Body(), &body)
+
+	if resp.StatusCode() < 200 || resp.StatusCode() > 299 {
+		return "", time.Time{}, &RetrieveError{
+			StatusCode:       resp.StatusCode(),
+			ErrorCode:        body.ErrorCode,
+			ErrorDescription: body.ErrorDescription,
+			Body:             append([]byte(nil), resp.Body()...),
+		}
+	}
+
+	if jsonErr != nil {
+		return "", time.Time{}, fmt.Errorf("oauth2: cannot parse token response: %w", jsonErr)
+	}
+
+	if body.AccessToken == "" {
+		return "", time.Time{}, errors.New("oauth2: server response missing access_token")
+	}
+
+	var expiry time.Time
+	if seconds, e := body.ExpiresIn.Int64(); e == nil && seconds > 0 {
+		expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+
+	return body.AccessToken, expiry, nil
+}
@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Environment chooses the proxy by the scheme and the target address,
+// like the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+// The https scheme uses the HTTPS proxy, otherwise the HTTP proxy.
+// The localhost and the loopback address are never proxied.
+type Environment struct {
+	httpProxy  *Dialer
+	httpsProxy *Dialer
+	noProxy    noProxy
+}
+
+// FromEnvironment to create a new proxy environment from the HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY environment variables, or the lowercase versions.
+func FromEnvironment() (*Environment, error) {
+	return NewEnvironment(
+		getenv("HTTP_PROXY", "http_proxy"),
+		getenv("HTTPS_PROXY", "https_proxy"),
+		getenv("NO_PROXY", "no_proxy"),
+	)
+}
+
+// NewEnvironment to create a new proxy environment,
+// an empty proxy URL means no proxy for the scheme.
+// noProxy is a comma-separated list of hosts, domains, IP addresses or CIDRs
+// with an optional port, or "*" to disable the proxy.
+func NewEnvironment(httpProxy, httpsProxy, noProxy string) (*Environment, error) {
+	env := &Environment{noProxy: parseNoProxy(noProxy)}
+
+	var e error
+	if httpProxy != "" {
+		if env.httpProxy, e = New(httpProxy); e != nil {
+			return nil, e
+		}
+	}
+
+	if httpsProxy != "" {
+		if env.httpsProxy, e = New(httpsProxy); e != nil {
+			return nil, e
+		}
+	}
+
+	return env, nil
+}
+
+// Proxy to get the proxy dialer of the scheme and the target address, nil means no proxy.
+func (env *Environment) Proxy(scheme, addr string) *Dialer {
+	host, port, e := net.SplitHostPort(addr)
+	if e != nil {
+		host = addr
+	}
+	host = strings.ToLower(host)
+
+	if host == "localhost" || isLoopback(host) || env.noProxy.match(host, port) {
+		return nil
+	}
+
+	if scheme == "https" {
+		return env.httpsProxy
+	}
+
+	return env.httpProxy
+}
+
+// Dial to connect to the target address of the scheme through the proxy of the scheme
+// and the address, or directly without a proxy.
+func (env *Environment) Dial(scheme, addr string) (net.Conn, error) {
+	return env.DialTimeout(scheme, addr, 0)
+}
+
+// DialTimeout to connect to the target address of the scheme with the timeout,
+// 0 means the default timeout.
+func (env *Environment) DialTimeout(scheme, addr string, timeout time.Duration) (net.Conn, error) {
+	if d := env.Proxy(scheme, addr); d != nil {
+		dialer := *d
+		dialer.Timeout = timeout
+
+		return dialer.DialScheme(scheme, addr)
+	}
+
+	if timeout <= 0 {
+		return fasthttp.Dial(addr)
+	}
+
+	return fasthttp.DialTimeout(addr, timeout)
+}
+
+func getenv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+type noProxy struct {
+	all     bool
+	rules   []noProxyRule
+	ipNets  []*net.IPNet
+	ipRules []net.IP
+}
+
+type noProxyRule struct {
+	domain    string
+	port      string
+	subdomain bool // the rule with a leading dot only matches the subdomains
+}
+
+func parseNoProxy(value string) noProxy {
+	var np noProxy
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		if entry == "*" {
+			np.all = true
+
+			return np
+		}
+
+		if _, ipNet, e := net.ParseCIDR(entry); e == nil {
+			np.ipNets = append(np.ipNets, ipNet)
+
+			continue
+		}
+
+		host, port, e := net.SplitHostPort(entry)
+		if e != nil {
+			host = entry
+			port = ""
+		}
+
+		if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+			np.ipRules = append(np.ipRules, ip)
+
+			continue
+		}
+
+		rule := noProxyRule{domain: strings.TrimPrefix(host, "*"), port: port}
+		if strings.HasPrefix(rule.domain, ".") {
+			rule.subdomain = true
+			rule.domain = rule.domain[1:]
+		}
+		np.rules = append(np.rules, rule)
+	}
+
+	return np
+}
+
+// match to check if the host and port must not be proxied.
+func (np noProxy) match(host, port string) bool {
+	if np.all {
+		return true
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, ipNet := range np.ipNets {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+
+		for _, rule := range np.ipRules {
+			if rule.Equal(ip) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, rule := range np.rules {
+		if rule.port != "" && rule.port != port {
+			continue
+		}
+
+		if strings.HasSuffix(host, "."+rule.domain) || !rule.subdomain && host == rule.domain {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrChunkedRequest is returned when a chunked request is sent to an http proxy for an HTTP target,
+// the request body must have a Content-Length.
+var ErrChunkedRequest = errors.New("proxy: chunked request body is not supported for an HTTP target")
+
+// maxHeadSize is the max size of the request line and the headers of a forwarded request.
+const maxHeadSize = 1 << 20
+
+// forwardConn is a connection to an http proxy for an HTTP target, without a tunnel.
+// The request line of every request is rewritten to the absolute-form by RFC 9112 section 3.2.2,
+// and the Proxy-Authorization header is added.
+type forwardConn struct {
+	net.Conn
+	target        string // the target address, like "example.com:80"
+	authorization string // the Proxy-Authorization header value, empty if no credentials
+
+	head []byte // the request line and the headers which are not written yet
+	body int64  // the request body bytes which are not written yet
+}
+
+// Write to rewrite and write the requests to the proxy.
+func (c *forwardConn) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		// The request body is written as is.
+		if c.body > 0 {
+			size := len(p)
+			if int64(size) > c.body {
+				size = int(c.body)
+			}
+
+			if _, e := c.Conn.Write(p[:size]); e != nil {
+				return 0, e
+			}
+			c.body -= int64(size)
+			p = p[size:]
+
+			continue
+		}
+
+		c.head = append(c.head, p...)
+		p = nil
+
+		end := bytes.Index(c.head, []byte("\r\n\r\n"))
+		if end < 0 {
+			if len(c.head) > maxHeadSize {
+				return 0, errors.New("proxy: request headers are too large")
+			}
+
+			break
+		}
+
+		head, rest := c.head[:end+4], c.head[end+4:]
+		rewritten, body, e := c.rewrite(head)
+		if e != nil {
+			return 0, e
+		}
+
+		if _, e := c.Conn.Write(rewritten); e != nil {
+			return 0, e
+		}
+
+		c.body = body
+		p = append([]byte(nil), rest...)
+		c.head = c.head[:0]
+	}
+
+	return n, nil
+}
+
+// rewrite to rewrite the request line to the absolute-form and add the Proxy-Authorization header,
+// it returns the request body size by the Content-Length header.
+func (c *forwardConn) rewrite(head []byte) ([]byte, int64, error) {
+	lineEnd := bytes.Index(head, []byte("\r\n"))
+	line := head[:lineEnd]
+
+	var body int64
+	for _, header := range bytes.Split(head[lineEnd+2:], []byte("\r\n")) {
+		i := bytes.IndexByte(header, ':')
+		if i < 0 {
+			continue
+		}
+
+		name, value := string(bytes.TrimSpace(header[:i])), string(bytes.TrimSpace(header[i+1:]))
+		switch {
+		case strings.EqualFold(name, "Content-Length"):
+			size, e := strconv.ParseInt(value, 10, 64)
+			if e != nil || size < 0 {
+				return nil, 0, errors.New("proxy: invalid Content-Length")
+			}
+			body = size
+		case strings.EqualFold(name, "Transfer-Encoding") && !strings.EqualFold(value, "identity"):
+			return nil, 0, ErrChunkedRequest
+		}
+	}
+
+	var buf bytes.Buffer
+	parts := bytes.SplitN(line, []byte(" "), 3)
+	if len(parts) == 3 && bytes.HasPrefix(parts[1], []byte("/")) {
+		buf.Write(parts[0])
+		buf.WriteString(" http://" + strings.TrimSuffix(c.target, ":80"))
+		buf.Write(parts[1])
+		buf.WriteByte(' ')
+		buf.Write(parts[2])
+	} else {
+		buf.Write(line)
+	}
+	buf.WriteString("\r\n")
+
+	if c.authorization != "" {
+		buf.WriteString("Proxy-Authorization: " + c.authorization + "\r\n")
+	}
+	buf.Write(head[lineEnd+2:])
+
+	return buf.Bytes(), body, nil
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+const (
+	socks5Version = 0x05
+
+	socks5NoAuth       = 0x00
+	socks5PasswordAuth = 0x02
+	socks5NoAcceptable = 0xff
+
+	socks5CmdConnect = 0x01
+
+	socks5IPv4   = 0x01
+	socks5Domain = 0x03
+	socks5IPv6   = 0x04
+)
+
+var socks5Replies = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// socks5Connect to connect to the target address by RFC 1928,
+// with the username and password authentication by RFC 1929 if the user is set.
+func socks5Connect(conn net.Conn, user *url.Userinfo, addr string) error {
+	host, portStr, e := net.SplitHostPort(addr)
+	if e != nil {
+		return fmt.Errorf("proxy: invalid address %s: %w", addr, e)
+	}
+
+	port, e := strconv.ParseUint(portStr, 10, 16)
+	if e != nil {
+		return fmt.Errorf("proxy: invalid port %s", portStr)
+	}
+
+	methods := []byte{socks5NoAuth}
+	if user != nil {
+		methods = []byte{socks5NoAuth, socks5PasswordAuth}
+	}
+
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, e := conn.Write(greeting); e != nil {
+		return fmt.Errorf("proxy: socks5 greeting: %w", e)
+	}
+
+	reply := make([]byte, 2)
+	if _, e := io.ReadFull(conn, reply); e != nil {
+		return fmt.Errorf("proxy: socks5 greeting: %w", e)
+	}
+
+	if reply[0] != socks5Version {
+		return fmt.Errorf("proxy: unexpected socks version %d", reply[0])
+	}
+
+	switch reply[1] {
+	case socks5NoAuth:
+	case socks5PasswordAuth:
+		if user == nil {
+			return errors.New("proxy: socks5 proxy requires authentication")
+		}
+
+		if e := socks5Authenticate(conn, user); e != nil {
+			return e
+		}
+	case socks5NoAcceptable:
+		return errors.New("proxy: socks5 proxy has no acceptable authentication method")
+	default:
+		return fmt.Errorf("proxy: unsupported socks5 authentication method %d", reply[1])
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, socks5IPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, socks5IPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("proxy: host name too long %s", host)
+		}
+
+		req = append(req, socks5Domain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+
+	if _, e := conn.Write(req); e != nil {
+		return fmt.Errorf("proxy: socks5 connect %s: %w", addr, e)
+	}
+
+	// The reply has the version, the reply code, a reserved byte and the bound address.
+	head := make([]byte, 4)
+	if _, e := io.ReadFull(conn, head); e != nil {
+		return fmt.Errorf("proxy: socks5 connect %s: %w", addr, e)
+	}
+
+	if head[1] != 0x00 {
+		msg, ok := socks5Replies[head[1]]
+		if !ok {
+			msg = "unknown reply " + strconv.Itoa(int(head[1]))
+		}
+
+		return fmt.Errorf("proxy: socks5 connect %s: %s", addr, msg)
+	}
+
+	var size int
+	switch head[3] {
+	case socks5IPv4:
+		size = net.IPv4len
+	case socks5IPv6:
+		size = net.IPv6len
+	case socks5Domain:
+		b := make([]byte, 1)
+		if _, e := io.ReadFull(conn, b); e != nil {
+			return fmt.Errorf("proxy: socks5 connect %s: %w", addr, e)
+		}
+		size = int(b[0])
+	default:
+		return fmt.Errorf("proxy: unsupported socks5 address type %d", head[3])
+	}
+
+	// The bound address and port are not used.
+	if _, e := io.ReadFull(conn, make([]byte, size+2)); e != nil {
+		return fmt.Errorf("proxy: socks5 connect %s: %w", addr, e)
+	}
+
+	return nil
+}
+
+func socks5Authenticate(conn net.Conn, user *url.Userinfo) error {
+	username := user.Username()
+	password, _ := user.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("proxy: socks5 username or password too long")
+	}
+
+	req := []byte{0x01, byte(len(username))}
+	req = append(req, username...)
+	req = append(req, byte(len(password)))
+	req = append(req, password...)
+
+	if _, e := conn.Write(req); e != nil {
+		return fmt.Errorf("proxy: socks5 authentication: %w", e)
+	}
+
+	reply := make([]byte, 2)
+	if _, e := io.ReadFull(conn, reply); e != nil {
+		return fmt.Errorf("proxy: socks5 authentication: %w", e)
+	}
+
+	if reply[1] != 0x00 {
+		return errors.New("proxy: socks5 authentication failed")
+	}
+
+	return nil
+}
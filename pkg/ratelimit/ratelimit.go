@@ -0,0 +1,193 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrLimited is returned if there is no token available without waiting.
+var ErrLimited = errors.New("rate limit exceeded")
+
+// Config is a token bucket configuration, N requests per interval with a burst.
+type Config struct {
+	// Requests is how much request per Interval.
+	Requests int
+	// Interval is the duration of the Requests, the default value is 1 second.
+	Interval time.Duration
+	// Burst is the max token in the bucket, the default value is Requests.
+	Burst int
+
+	// PerHost to limit every host with its own bucket,
+	// otherwise every host share 1 bucket.
+	PerHost bool
+	// FailFast to fail with ErrLimited instead of waiting for a token.
+	FailFast bool
+}
+
+// Limiter is a token bucket, safe for concurrent use.
+type Limiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// New to create a new token bucket, the bucket is full at the start.
+func New(cfg *Config) *Limiter {
+	interval := time.Second
+	if cfg.Interval > 0 {
+		interval = cfg.Interval
+	}
+
+	burst := cfg.Burst
+	if burst < 1 {
+		burst = cfg.Requests
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:   float64(cfg.Requests) / interval.Seconds(),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow to take a token without waiting.
+func (l *Limiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+
+	return true
+}
+
+// Wait to take a token, waiting until a token is available or the context is done.
+// If the context deadline comes before the token, ErrLimited is returned without waiting.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.refill(now)
+
+	if l.tokens >= 1 {
+		l.tokens--
+		l.mu.Unlock()
+
+		return nil
+	}
+
+	if l.rate <= 0 {
+		l.mu.Unlock()
+
+		return ErrLimited
+	}
+
+	wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(wait)) {
+		l.mu.Unlock()
+
+		return ErrLimited
+	}
+
+	// Reserve the token, the next caller waits after this reservation.
+	l.tokens--
+	l.mu.Unlock()
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.refill(time.Now())
+		l.tokens++
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.mu.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// refill to add the tokens by the elapsed time, must be called with the lock.
+func (l *Limiter) refill(now time.Time) {
+	elapsed := now.Sub(l.last)
+	if elapsed <= 0 {
+		return
+	}
+
+	l.tokens += elapsed.Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// Group is a set of token buckets by the host, safe for concurrent use.
+// If PerHost is false, every host share the same bucket.
+type Group struct {
+	cfg Config
+
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+}
+
+// NewGroup to create a new set of token buckets with the same configuration.
+func NewGroup(cfg *Config) *Group {
+	return &Group{
+		cfg:      *cfg,
+		limiters: make(map[string]*Limiter),
+	}
+}
+
+// Get to get the token bucket of the host, the bucket is created if not exists.
+func (g *Group) Get(host string) *Limiter {
+	if !g.cfg.PerHost {
+		host = ""
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	l, ok := g.limiters[host]
+	if !ok {
+		l = New(&g.cfg)
+		g.limiters[host] = l
+	}
+
+	return l
+}
+
+// Take to take a token of the host,
+// waiting for a token or failing fast with ErrLimited by the configuration.
+func (g *Group) Take(ctx context.Context, host string) error {
+	l := g.Get(host)
+	if g.cfg.FailFast {
+		if !l.Allow() {
+			return ErrLimited
+		}
+
+		return nil
+	}
+
+	return l.Wait(ctx)
+}
@@ -0,0 +1,104 @@
+package retry
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff to get the wait duration before the next attempt.
+// attempt is the number of the failed attempt, starting from 1.
+// prev is the previous wait duration, zero for the first retry.
+// Backoff must be safe for concurrent use.
+type Backoff interface {
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+// Constant is a backoff with the same interval for every attempt.
+type Constant struct {
+	Interval time.Duration
+}
+
+// Next to get the wait duration before the next attempt.
+func (b Constant) Next(attempt int, prev time.Duration) time.Duration {
+	return b.Interval
+}
+
+// Exponential is a backoff with the interval multiplied for every attempt,
+// Base * Multiplier^(attempt-1) and capped by Max.
+// The default Multiplier is 2.
+type Exponential struct {
+	Base       time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// Next to get the wait duration before the next attempt.
+func (b Exponential) Next(attempt int, prev time.Duration) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	return exponential(b.Base, b.Max, multiplier, attempt)
+}
+
+// FullJitter is an exponential backoff with a random wait duration
+// between 0 and the exponential interval.
+type FullJitter struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Next to get the wait duration before the next attempt.
+func (b FullJitter) Next(attempt int, prev time.Duration) time.Duration {
+	d := exponential(b.Base, b.Max, 2, attempt)
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// DecorrelatedJitter is a backoff with a random wait duration
+// between Base and 3 times of the previous wait duration, capped by Max.
+type DecorrelatedJitter struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Next to get the wait duration before the next attempt.
+func (b DecorrelatedJitter) Next(attempt int, prev time.Duration) time.Duration {
+	if prev < b.Base {
+		prev = b.Base
+	}
+
+	d := b.Base
+	if upper := prev * 3; upper > b.Base {
+		d += time.Duration(rand.Int63n(int64(upper - b.Base)))
+	}
+
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+
+	return d
+}
+
+func exponential(base, max time.Duration, multiplier float64, attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(base) * math.Pow(multiplier, float64(attempt-1))
+	if max > 0 && d > float64(max) {
+		return max
+	}
+
+	// Avoid overflow if the attempt is too big without Max.
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(d)
+}
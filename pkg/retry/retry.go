@@ -1,7 +1,11 @@
 package retry
 
 import (
+	"context"
+	"crypto/rand"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/valyala/fasthttp"
@@ -13,6 +17,81 @@ type Config struct {
 	Timeouts      time.Duration
 	Interval      time.Duration
 	RetryAttempts int
+
+	// Backoff to get the wait duration between attempts,
+	// the default is a Constant backoff with the Interval.
+	Backoff Backoff
+	// MaxElapsedTime to stop retrying if the next attempt
+	// will start after the elapsed time, 0 means no limit.
+	MaxElapsedTime time.Duration
+
+	// StatusCodes to retry the HTTP call if the response has one of the status codes.
+	StatusCodes []int
+	// Condition to retry the HTTP call if it returns true for the response.
+	Condition func(resp *fasthttp.Response) bool
+	// MaxRetryAfter is the max wait duration from the Retry-After header,
+	// the default is DefaultMaxRetryAfter.
+	MaxRetryAfter time.Duration
+	// RetryNonIdempotent to retry the non-idempotent method like POST and PATCH,
+	// an Idempotency-Key header is attached and reused in every attempt.
+	RetryNonIdempotent bool
+
+	// OnRetry is called before waiting for the next attempt, with the failed attempt,
+	// its response or error, and the wait duration.
+	OnRetry func(attempt int, resp *fasthttp.Response, e error, wait time.Duration)
+}
+
+// Policy is the retry configuration of an HTTP call.
+type Policy struct {
+	// Attempts is how much to retry the HTTP call after the first attempt is failed,
+	// 0 means no retries.
+	Attempts       int
+	Backoff        Backoff
+	MaxElapsedTime time.Duration
+
+	// By default, only the failed HTTP call is retried.
+	// Use StatusCodes or Condition to retry the HTTP call by the response,
+	// the Retry-After header of the response is respected.
+	StatusCodes   []int
+	Condition     func(resp *fasthttp.Response) bool
+	MaxRetryAfter time.Duration
+
+	// By default, only the idempotent method is retried.
+	// Use RetryNonIdempotent to retry the POST and PATCH method with an Idempotency-Key header.
+	RetryNonIdempotent bool
+}
+
+// HeaderIdempotencyKey is the header to make the non-idempotent method safe to retry.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// DefaultStatusCodes is the status codes which are usually safe to retry.
+var DefaultStatusCodes = []int{
+	fasthttp.StatusTooManyRequests,
+	fasthttp.StatusBadGateway,
+	fasthttp.StatusServiceUnavailable,
+	fasthttp.StatusGatewayTimeout,
+}
+
+// DefaultMaxRetryAfter is the default max wait duration from the Retry-After header.
+const DefaultMaxRetryAfter = 30 * time.Second
+
+// Permanent is implemented by the errors which must not be retried,
+// like a policy or security refusal. The HTTP retry returns the error immediately.
+type Permanent interface {
+	Permanent() bool
+}
+
+// Doer is an HTTP client to call an HTTP once, like *fasthttp.Client.
+type Doer interface {
+	DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error
+}
+
+// DoerFunc is a function to call an HTTP once.
+type DoerFunc func(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error
+
+// DoTimeout to call the function.
+func (f DoerFunc) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	return f(req, resp, timeout)
 }
 
 // New to create a new instance for http retry.
@@ -32,27 +111,226 @@ func New(cfg *Config) *Config {
 		interval = cfg.Interval
 	}
 
+	backoff := cfg.Backoff
+	if backoff == nil {
+		backoff = Constant{Interval: interval}
+	}
+
+	maxRetryAfter := DefaultMaxRetryAfter
+	if cfg.MaxRetryAfter > 0 {
+		maxRetryAfter = cfg.MaxRetryAfter
+	}
+
 	return &Config{
-		Attempts: attempts,
-		Timeouts: timeouts,
-		Interval: interval,
+		Attempts:       attempts,
+		Timeouts:       timeouts,
+		Interval:       interval,
+		Backoff:        backoff,
+		MaxElapsedTime: cfg.MaxElapsedTime,
+		StatusCodes:    cfg.StatusCodes,
+		Condition:      cfg.Condition,
+		MaxRetryAfter:  maxRetryAfter,
+
+		RetryNonIdempotent: cfg.RetryNonIdempotent,
+		OnRetry:            cfg.OnRetry,
 	}
 }
 
 // Do to running HTTP retry.
-func (r *Config) Do(req *fasthttp.Request, resp *fasthttp.Response, c *fasthttp.Client) (*fasthttp.Response, error) {
-	r.RetryAttempts++
+func (r *Config) Do(req *fasthttp.Request, resp *fasthttp.Response, c Doer) (*fasthttp.Response, error) {
+	return r.DoContext(context.Background(), req, resp, c)
+}
+
+// DoContext to running HTTP retry until the context is done.
+// If the context is canceled or the deadline is exceeded, the in-flight HTTP call
+// is abandoned and ctx.Err() is returned without any further attempts.
+func (r *Config) DoContext(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response, c Doer) (*fasthttp.Response, error) {
+	backoff := r.Backoff
+	if backoff == nil {
+		backoff = Constant{Interval: r.Interval}
+	}
+
+	attempts := r.Attempts
+	if !isIdempotent(req.Header.Method()) {
+		if !r.RetryNonIdempotent {
+			attempts = 0
+		} else if len(req.Header.Peek(HeaderIdempotencyKey)) == 0 {
+			req.Header.Set(HeaderIdempotencyKey, newIdempotencyKey())
+		}
+	}
+
+	var wait time.Duration
+	start := time.Now()
+
+	for {
+		if e := ctx.Err(); e != nil {
+			return resp, e
+		}
+
+		r.RetryAttempts++
+
+		e := doTimeout(ctx, req, resp, c, r.Timeouts)
+		if e == nil && !r.shouldRetry(resp) {
+			return resp, nil
+		}
+
+		if ctx.Err() != nil {
+			return resp, ctx.Err()
+		}
+
+		if isPermanent(e) {
+			return resp, e
+		}
+
+		// The error or the response of the last attempt is returned,
+		// RetryAttempts is how much the HTTP was called.
+		if r.RetryAttempts > attempts {
+			return resp, e
+		}
+
+		wait = backoff.Next(r.RetryAttempts, wait)
+		if e == nil {
+			if retryAfter, ok := parseRetryAfter(resp, time.Now()); ok {
+				wait = retryAfter
+				if r.MaxRetryAfter > 0 && wait > r.MaxRetryAfter {
+					wait = r.MaxRetryAfter
+				}
+			}
+		}
+
+		if r.MaxElapsedTime > 0 && time.Since(start)+wait > r.MaxElapsedTime {
+			return resp, e
+		}
+
+		if r.OnRetry != nil {
+			r.OnRetry(r.RetryAttempts, resp, e, wait)
+		}
+
+		if e := sleep(ctx, wait); e != nil {
+			return resp, e
+		}
+	}
+}
+
+// isPermanent to check if the error or one of its wrapped errors must not be retried.
+func isPermanent(e error) bool {
+	var p Permanent
+
+	return errors.As(e, &p) && p.Permanent()
+}
+
+// isIdempotent to check if the HTTP method is safe to retry.
+func isIdempotent(method []byte) bool {
+	switch string(method) {
+	case fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodOptions,
+		fasthttp.MethodTrace, fasthttp.MethodPut, fasthttp.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// newIdempotencyKey to generate a random UUID version 4.
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// shouldRetry to check if the response must be retried by the status codes or the condition.
+func (r *Config) shouldRetry(resp *fasthttp.Response) bool {
+	for _, code := range r.StatusCodes {
+		if resp.StatusCode() == code {
+			return true
+		}
+	}
+
+	return r.Condition != nil && r.Condition(resp)
+}
+
+// parseRetryAfter to get the wait duration from the Retry-After header,
+// the value is in seconds or an HTTP-date.
+func parseRetryAfter(resp *fasthttp.Response, now time.Time) (time.Duration, bool) {
+	v := resp.Header.Peek(fasthttp.HeaderRetryAfter)
+	if len(v) == 0 {
+		return 0, false
+	}
 
-	if e := c.DoTimeout(req, resp, r.Timeouts); e != nil {
-		if r.RetryAttempts <= r.Attempts {
-			time.Sleep(r.Interval)
-			r.Do(req, resp, c)
+	if seconds, e := strconv.Atoi(string(v)); e == nil {
+		if seconds < 0 {
+			return 0, false
 		}
 
-		if r.RetryAttempts > r.Attempts {
-			return resp, errors.New("Request Timeout")
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, e := fasthttp.ParseHTTPDate(v)
+	if e != nil {
+		return 0, false
+	}
+
+	if wait := date.Sub(now); wait > 0 {
+		return wait, true
+	}
+
+	return 0, true
+}
+
+// doTimeout to calling an HTTP once.
+// The timeout is shortened to the context deadline if the deadline comes first.
+func doTimeout(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response, c Doer, timeout time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < timeout {
+			timeout = remaining
 		}
 	}
 
-	return resp, nil
+	// The context cannot be canceled, no need to watch it.
+	if ctx.Done() == nil {
+		return c.DoTimeout(req, resp, timeout)
+	}
+
+	// fasthttp cannot abort a running call, so the call works with its own copies.
+	// If the context is done first, the copies are released after the call returns.
+	callReq := fasthttp.AcquireRequest()
+	callResp := fasthttp.AcquireResponse()
+	req.CopyTo(callReq)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.DoTimeout(callReq, callResp, timeout)
+	}()
+
+	select {
+	case e := <-done:
+		callResp.CopyTo(resp)
+		fasthttp.ReleaseRequest(callReq)
+		fasthttp.ReleaseResponse(callResp)
+
+		return e
+	case <-ctx.Done():
+		go func() {
+			<-done
+			fasthttp.ReleaseRequest(callReq)
+			fasthttp.ReleaseResponse(callResp)
+		}()
+
+		return ctx.Err()
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
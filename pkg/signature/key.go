@@ -0,0 +1,81 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+// Key is a signing key of an algorithm.
+type Key interface {
+	// Algorithm is the alg parameter of the signature, like "hmac-sha256".
+	Algorithm() string
+	Sign(data []byte) ([]byte, error)
+	Verify(data, sig []byte) error
+}
+
+type hmacKey []byte
+
+// HMACSHA256 to create a shared secret key with the hmac-sha256 algorithm.
+func HMACSHA256(secret []byte) Key {
+	return hmacKey(secret)
+}
+
+func (k hmacKey) Algorithm() string {
+	return "hmac-sha256"
+}
+
+func (k hmacKey) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k)
+	mac.Write(data)
+
+	return mac.Sum(nil), nil
+}
+
+func (k hmacKey) Verify(data, sig []byte) error {
+	expected, _ := k.Sign(data)
+	if !hmac.Equal(expected, sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+type ed25519Key struct {
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// Ed25519 to create a private key with the ed25519 algorithm.
+func Ed25519(private ed25519.PrivateKey) Key {
+	return ed25519Key{
+		private: private,
+		public:  private.Public().(ed25519.PublicKey),
+	}
+}
+
+// Ed25519Public to create a public key with the ed25519 algorithm, only to verify.
+func Ed25519Public(public ed25519.PublicKey) Key {
+	return ed25519Key{public: public}
+}
+
+func (k ed25519Key) Algorithm() string {
+	return "ed25519"
+}
+
+func (k ed25519Key) Sign(data []byte) ([]byte, error) {
+	if k.private == nil {
+		return nil, errors.New("signature: ed25519 private key is required to sign")
+	}
+
+	return ed25519.Sign(k.private, data), nil
+}
+
+func (k ed25519Key) Verify(data, sig []byte) error {
+	if !ed25519.Verify(k.public, data, sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package signature
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// message is the HTTP request components to sign.
+type message struct {
+	method    string
+	scheme    string
+	authority string
+	path      string
+	query     string
+	header    func(name string) string
+}
+
+func (m message) requestTarget() string {
+	if m.query == "" {
+		return m.path
+	}
+
+	return m.path + "?" + m.query
+}
+
+func fromFastHTTP(req *fasthttp.Request) message {
+	uri := req.URI()
+	scheme := strings.ToLower(string(uri.Scheme()))
+
+	path := string(uri.PathOriginal())
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	return message{
+		method:    string(req.Header.Method()),
+		scheme:    scheme,
+		authority: authority(scheme, string(uri.Host())),
+		path:      emptyPath(path),
+		query:     string(uri.QueryString()),
+		header: func(name string) string {
+			values := make([]string, 0, 1)
+			req.Header.VisitAll(func(key, value []byte) {
+				if strings.EqualFold(string(key), name) {
+					values = append(values, strings.TrimSpace(string(value)))
+				}
+			})
+
+			return strings.Join(values, ", ")
+		},
+	}
+}
+
+func fromHTTP(r *http.Request) message {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return message{
+		method:    r.Method,
+		scheme:    scheme,
+		authority: authority(scheme, r.Host),
+		path:      emptyPath(r.URL.EscapedPath()),
+		query:     r.URL.RawQuery,
+		header: func(name string) string {
+			values := r.Header.Values(name)
+			if strings.EqualFold(name, "host") {
+				values = []string{r.Host}
+			}
+
+			trimmed := make([]string, 0, len(values))
+			for _, value := range values {
+				trimmed = append(trimmed, strings.TrimSpace(value))
+			}
+
+			return strings.Join(trimmed, ", ")
+		},
+	}
+}
+
+// authority to normalize the host, the default port is removed.
+func authority(scheme, host string) string {
+	host = strings.ToLower(host)
+	if scheme == "http" {
+		return strings.TrimSuffix(host, ":80")
+	} else if scheme == "https" {
+		return strings.TrimSuffix(host, ":443")
+	}
+
+	return host
+}
+
+func emptyPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+
+	return path
+}
@@ -0,0 +1,345 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Header names of HTTP Message Signatures (RFC 9421) and Digest Fields (RFC 9530).
+const (
+	HeaderSignature      = "Signature"
+	HeaderSignatureInput = "Signature-Input"
+	HeaderContentDigest  = "Content-Digest"
+)
+
+var (
+	// ErrNoSignature is returned when the request has no signature with the label.
+	ErrNoSignature = errors.New("signature: no signature found")
+	// ErrInvalidSignature is returned when the signature does not match.
+	ErrInvalidSignature = errors.New("signature: invalid signature")
+	// ErrExpired is returned when the signature is expired.
+	ErrExpired = errors.New("signature: signature is expired")
+	// ErrDigestMismatch is returned when the Content-Digest does not match the body.
+	ErrDigestMismatch = errors.New("signature: content digest mismatch")
+)
+
+// DefaultComponents is the components to sign if the components are not set.
+var DefaultComponents = []string{"@method", "@target-uri", "content-digest", "content-type"}
+
+// Config is a request signing configuration.
+type Config struct {
+	KeyID string
+	Key   Key
+	// Label of the signature, the default value is "sig1".
+	Label string
+	// Components to sign, like "@method", "@authority", "@path", "@query",
+	// "@target-uri" or a header name. The default value is DefaultComponents.
+	// The content-digest component is computed from the body before signing.
+	Components []string
+	// Expires to add the expiry time of the signature, 0 means no expiry.
+	Expires time.Duration
+	// Nonce to add a nonce parameter, like a random string for every request.
+	Nonce func() string
+}
+
+// Signer signs a request, safe for concurrent use.
+type Signer struct {
+	cfg Config
+}
+
+// New to create a new signer.
+func New(cfg *Config) *Signer {
+	c := *cfg
+	if c.Label == "" {
+		c.Label = "sig1"
+	}
+
+	if len(c.Components) == 0 {
+		c.Components = DefaultComponents
+	}
+
+	return &Signer{cfg: c}
+}
+
+// Sign to set the Content-Digest, Signature-Input and Signature headers of the request.
+// It must be called after the body is final.
+func (s *Signer) Sign(req *fasthttp.Request) error {
+	if s.cfg.Key == nil {
+		return errors.New("signature: key is required")
+	}
+
+	components := make([]string, 0, len(s.cfg.Components))
+	for _, component := range s.cfg.Components {
+		component = strings.ToLower(component)
+		if component == "content-digest" {
+			req.Header.Set(HeaderContentDigest, ContentDigest(req.Body()))
+		}
+
+		// A header component which is not in the request cannot be signed, like the content-type of GET.
+		if !strings.HasPrefix(component, "@") && len(req.Header.Peek(component)) == 0 {
+			continue
+		}
+
+		components = append(components, component)
+	}
+
+	created := time.Now().Unix()
+	params := serializeParams(components, created, s.cfg)
+
+	msg := fromFastHTTP(req)
+	base, e := signatureBase(msg, components, params)
+	if e != nil {
+		return e
+	}
+
+	sig, e := s.cfg.Key.Sign([]byte(base))
+	if e != nil {
+		return e
+	}
+
+	req.Header.Set(HeaderSignatureInput, s.cfg.Label+"="+params)
+	req.Header.Set(HeaderSignature, s.cfg.Label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+
+	return nil
+}
+
+// ContentDigest to compute the Content-Digest header value of the body with SHA-256.
+func ContentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// VerifyContentDigest to check the Content-Digest header value with the body,
+// sha-256 and sha-512 are supported.
+func VerifyContentDigest(digest string, body []byte) error {
+	for _, member := range strings.Split(digest, ",") {
+		member = strings.TrimSpace(member)
+
+		i := strings.Index(member, "=")
+		if i < 0 {
+			continue
+		}
+
+		value, e := decodeByteSequence(member[i+1:])
+		if e != nil {
+			return e
+		}
+
+		var sum []byte
+		switch strings.ToLower(member[:i]) {
+		case "sha-256":
+			s := sha256.Sum256(body)
+			sum = s[:]
+		case "sha-512":
+			s := sha512.Sum512(body)
+			sum = s[:]
+		default:
+			continue
+		}
+
+		if !hmac.Equal(sum, value) {
+			return ErrDigestMismatch
+		}
+
+		return nil
+	}
+
+	return ErrDigestMismatch
+}
+
+// Verify to verify the signature with the label of a request.
+func Verify(req *fasthttp.Request, label string, key Key) error {
+	return verify(fromFastHTTP(req), req.Body(), label, key)
+}
+
+// VerifyRequest to verify the signature with the label of a net/http request,
+// like the request of an HTTP test server. The body must be read before.
+func VerifyRequest(r *http.Request, body []byte, label string, key Key) error {
+	return verify(fromHTTP(r), body, label, key)
+}
+
+func verify(msg message, body []byte, label string, key Key) error {
+	input, ok := dictionaryMember(msg.header(HeaderSignatureInput), label)
+	if !ok {
+		return ErrNoSignature
+	}
+
+	sigValue, ok := dictionaryMember(msg.header(HeaderSignature), label)
+	if !ok {
+		return ErrNoSignature
+	}
+
+	sig, e := decodeByteSequence(sigValue)
+	if e != nil {
+		return e
+	}
+
+	components, params, e := parseInput(input)
+	if e != nil {
+		return e
+	}
+
+	if expires, ok := params["expires"]; ok {
+		unix, e := strconv.ParseInt(expires, 10, 64)
+		if e != nil || time.Now().Unix() > unix {
+			return ErrExpired
+		}
+	}
+
+	base, e := signatureBase(msg, components, input)
+	if e != nil {
+		return e
+	}
+
+	if e := key.Verify([]byte(base), sig); e != nil {
+		return e
+	}
+
+	for _, component := range components {
+		if component == "content-digest" {
+			return VerifyContentDigest(msg.header(HeaderContentDigest), body)
+		}
+	}
+
+	return nil
+}
+
+// serializeParams to serialize the signature parameters, the value of Signature-Input.
+func serializeParams(components []string, created int64, cfg Config) string {
+	var b strings.Builder
+
+	b.WriteString("(")
+	for i, component := range components {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(strconv.Quote(component))
+	}
+	b.WriteString(")")
+
+	b.WriteString(";created=" + strconv.FormatInt(created, 10))
+	if cfg.Expires > 0 {
+		b.WriteString(";expires=" + strconv.FormatInt(created+int64(cfg.Expires/time.Second), 10))
+	}
+	if cfg.KeyID != "" {
+		b.WriteString(";keyid=" + strconv.Quote(cfg.KeyID))
+	}
+	b.WriteString(";alg=" + strconv.Quote(cfg.Key.Algorithm()))
+	if cfg.Nonce != nil {
+		b.WriteString(";nonce=" + strconv.Quote(cfg.Nonce()))
+	}
+
+	return b.String()
+}
+
+// signatureBase to create the signature base by RFC 9421 section 2.5.
+func signatureBase(msg message, components []string, params string) (string, error) {
+	var b strings.Builder
+
+	for _, component := range components {
+		value, e := componentValue(msg, component)
+		if e != nil {
+			return "", e
+		}
+
+		b.WriteString(strconv.Quote(component) + ": " + value + "\n")
+	}
+	b.WriteString(`"@signature-params": ` + params)
+
+	return b.String(), nil
+}
+
+func componentValue(msg message, component string) (string, error) {
+	switch component {
+	case "@method":
+		return strings.ToUpper(msg.method), nil
+	case "@target-uri":
+		return msg.scheme + "://" + msg.authority + msg.requestTarget(), nil
+	case "@authority":
+		return msg.authority, nil
+	case "@scheme":
+		return msg.scheme, nil
+	case "@request-target":
+		return msg.requestTarget(), nil
+	case "@path":
+		return msg.path, nil
+	case "@query":
+		return "?" + msg.query, nil
+	}
+
+	if strings.HasPrefix(component, "@") {
+		return "", fmt.Errorf("signature: unsupported component %s", component)
+	}
+
+	value := msg.header(component)
+	if value == "" {
+		return "", fmt.Errorf("signature: missing header %s", component)
+	}
+
+	return value, nil
+}
+
+// dictionaryMember to get the member value of a structured field dictionary.
+func dictionaryMember(dictionary, label string) (string, bool) {
+	for _, member := range strings.Split(dictionary, ",") {
+		member = strings.TrimSpace(member)
+		if strings.HasPrefix(member, label+"=") {
+			return member[len(label)+1:], true
+		}
+	}
+
+	return "", false
+}
+
+// parseInput to parse the covered components and the parameters of Signature-Input.
+func parseInput(input string) ([]string, map[string]string, error) {
+	end := strings.Index(input, ")")
+	if !strings.HasPrefix(input, "(") || end < 0 {
+		return nil, nil, fmt.Errorf("signature: invalid signature input %q", input)
+	}
+
+	components := make([]string, 0)
+	for _, item := range strings.Fields(input[1:end]) {
+		component, e := strconv.Unquote(item)
+		if e != nil {
+			return nil, nil, fmt.Errorf("signature: invalid component %s", item)
+		}
+		components = append(components, component)
+	}
+
+	params := make(map[string]string)
+	for _, param := range strings.Split(input[end+1:], ";") {
+		i := strings.Index(param, "=")
+		if i < 0 {
+			continue
+		}
+
+		value := param[i+1:]
+		if unquoted, e := strconv.Unquote(value); e == nil {
+			value = unquoted
+		}
+		params[param[:i]] = value
+	}
+
+	return components, params, nil
+}
+
+func decodeByteSequence(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+		return nil, fmt.Errorf("signature: invalid byte sequence %q", value)
+	}
+
+	return base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+}
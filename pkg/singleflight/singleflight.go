@@ -0,0 +1,65 @@
+package singleflight
+
+import (
+	"context"
+	"sync"
+)
+
+// Group coalesces the calls with the same key,
+// only 1 call of a key is running at the same time and every caller shares its result.
+// Group is safe for concurrent use.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	value   interface{}
+	waiters int
+}
+
+// New to create a new group.
+func New() *Group {
+	return &Group{calls: make(map[string]*flight)}
+}
+
+// Do to run fn once for every concurrent caller with the same key.
+// fn runs in its own goroutine, so it is not canceled if the caller stops waiting.
+// If the context is done before fn returns, the caller stops waiting and gets the context error.
+// shared reports if the value is given to more than 1 caller.
+func (g *Group) Do(ctx context.Context, key string, fn func() interface{}) (v interface{}, shared bool, e error) {
+	g.mu.Lock()
+	f, ok := g.calls[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		g.calls[key] = f
+
+		go g.run(key, f, fn)
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		g.mu.Lock()
+		shared = f.waiters > 1
+		g.mu.Unlock()
+
+		return f.value, shared, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+func (g *Group) run(key string, f *flight, fn func() interface{}) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+
+		close(f.done)
+	}()
+
+	f.value = fn()
+}
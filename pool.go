@@ -0,0 +1,240 @@
+package panggilhttp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// PoolConfig is the connection pool configuration of the client,
+// a zero value means the fasthttp default value.
+type PoolConfig struct {
+	// MaxConnsPerHost is the max connections to every host,
+	// the HTTP call waits for a free connection if it is reached.
+	MaxConnsPerHost int
+	// MaxIdleConnDuration to close the idle connections after the duration.
+	MaxIdleConnDuration time.Duration
+	// MaxConnWaitTimeout is how long the HTTP call waits for a free connection,
+	// 0 means the HTTP call fails immediately if MaxConnsPerHost is reached.
+	MaxConnWaitTimeout time.Duration
+	// MaxConnDuration to close the connections after the duration, even if it is in use.
+	MaxConnDuration time.Duration
+
+	// ReadTimeout and WriteTimeout is the timeout of reading the response
+	// and writing the request, the HTTP call timeout is still applied.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// DialTimeout is the timeout to connect to the host or the proxy,
+	// the default value is 3 seconds.
+	DialTimeout time.Duration
+}
+
+// HostStats is the connection statistics of a host.
+// Active is the connections in use and Idle is the open connections not in use.
+type HostStats struct {
+	Open   int
+	Active int
+	Idle   int
+}
+
+// poolStats counts the open connections and the in-flight HTTP calls by the host address.
+type poolStats struct {
+	mu       sync.Mutex
+	open     map[string]int
+	inFlight map[string]int
+}
+
+// WithPool to tune the connection pool of the client.
+func (cl *Client) WithPool(cfg *PoolConfig) *Client {
+	if cfg.MaxConnsPerHost < 0 || cfg.MaxIdleConnDuration < 0 || cfg.MaxConnWaitTimeout < 0 ||
+		cfg.MaxConnDuration < 0 || cfg.ReadTimeout < 0 || cfg.WriteTimeout < 0 || cfg.DialTimeout < 0 {
+		cl.errs = append(cl.errs, ErrInvalidPool)
+
+		return cl
+	}
+
+	cl.hc.MaxConnsPerHost = cfg.MaxConnsPerHost
+	cl.hc.MaxIdleConnDuration = cfg.MaxIdleConnDuration
+	cl.hc.MaxConnWaitTimeout = cfg.MaxConnWaitTimeout
+	cl.hc.MaxConnDuration = cfg.MaxConnDuration
+	cl.hc.ReadTimeout = cfg.ReadTimeout
+	cl.hc.WriteTimeout = cfg.WriteTimeout
+	cl.dialTimeout = cfg.DialTimeout
+	cl.resetClients()
+
+	return cl
+}
+
+// Stats to get the connection statistics of every host address, like "example.com:443".
+func (cl *Client) Stats() map[string]HostStats {
+	cl.stats.mu.Lock()
+	defer cl.stats.mu.Unlock()
+
+	stats := make(map[string]HostStats)
+	for addr, open := range cl.stats.open {
+		stats[addr] = HostStats{Open: open}
+	}
+
+	for addr, inFlight := range cl.stats.inFlight {
+		s := stats[addr]
+
+		s.Active = inFlight
+		if s.Active > s.Open {
+			s.Active = s.Open
+		}
+		stats[addr] = s
+	}
+
+	for addr, s := range stats {
+		s.Idle = s.Open - s.Active
+		if s == (HostStats{}) {
+			delete(stats, addr)
+
+			continue
+		}
+		stats[addr] = s
+	}
+
+	return stats
+}
+
+// dial to connect to the host of the scheme, through the proxy if set, and count the open connection.
+// The client TLS configuration is used to connect to an https proxy.
+func (cl *Client) dial(scheme, addr string) (net.Conn, error) {
+	var (
+		conn net.Conn
+		e    error
+	)
+
+	d := cl.proxy
+	if d == nil && cl.proxyEnv != nil {
+		d = cl.proxyEnv.Proxy(scheme, addr)
+	}
+
+	switch {
+	case d != nil:
+		dialer := *d
+		dialer.Timeout = cl.dialTimeout
+		dialer.TLSConfig = cl.hc.TLSConfig
+		conn, e = dialer.DialScheme(scheme, addr)
+	case cl.dialTimeout > 0:
+		conn, e = fasthttp.DialTimeout(addr, cl.dialTimeout)
+	default:
+		conn, e = fasthttp.Dial(addr)
+	}
+	if e != nil {
+		return nil, e
+	}
+
+	cl.stats.add(cl.stats.open, addr, 1)
+	conn = &trackedConn{Conn: conn, close: func() { cl.stats.add(cl.stats.open, addr, -1) }}
+
+	if scheme != "https" {
+		return conn, nil
+	}
+
+	return cl.handshake(conn, addr)
+}
+
+// doDeadline to call an HTTP once and count the in-flight HTTP call.
+func (cl *Client) doDeadline(req *fasthttp.Request, resp *fasthttp.Response, deadline time.Time) error {
+	hc, e := cl.schemeClient(string(req.URI().Scheme()))
+	if e != nil {
+		return e
+	}
+
+	addr := hostAddr(req)
+	cl.stats.add(cl.stats.inFlight, addr, 1)
+	defer cl.stats.add(cl.stats.inFlight, addr, -1)
+
+	return hc.DoDeadline(req, resp, deadline)
+}
+
+// schemeClient to get the client of the scheme, it is created from the client configuration
+// on the first HTTP call of the scheme. The client dials with its scheme,
+// so the proxy is chosen by the scheme of the request.
+func (cl *Client) schemeClient(scheme string) (*fasthttp.Client, error) {
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("unsupported protocol %q. http and https are supported", scheme)
+	}
+
+	cl.clientsMu.Lock()
+	defer cl.clientsMu.Unlock()
+
+	if hc, ok := cl.clients[scheme]; ok {
+		return hc, nil
+	}
+
+	hc := &fasthttp.Client{
+		Name:                          cl.hc.Name,
+		NoDefaultUserAgentHeader:      cl.hc.NoDefaultUserAgentHeader,
+		Dial:                          func(addr string) (net.Conn, error) { return cl.dial(scheme, addr) },
+		MaxConnsPerHost:               cl.hc.MaxConnsPerHost,
+		MaxIdleConnDuration:           cl.hc.MaxIdleConnDuration,
+		MaxConnDuration:               cl.hc.MaxConnDuration,
+		ReadBufferSize:                cl.hc.ReadBufferSize,
+		WriteBufferSize:               cl.hc.WriteBufferSize,
+		ReadTimeout:                   cl.hc.ReadTimeout,
+		WriteTimeout:                  cl.hc.WriteTimeout,
+		MaxResponseBodySize:           cl.hc.MaxResponseBodySize,
+		DisableHeaderNamesNormalizing: cl.hc.DisableHeaderNamesNormalizing,
+		MaxConnWaitTimeout:            cl.hc.MaxConnWaitTimeout,
+	}
+	cl.clients[scheme] = hc
+
+	return hc, nil
+}
+
+// resetClients to create the clients of the schemes again with the changed configuration
+// on the next HTTP call, the idle connections of the current clients are closed.
+func (cl *Client) resetClients() {
+	cl.clientsMu.Lock()
+	defer cl.clientsMu.Unlock()
+
+	for scheme, hc := range cl.clients {
+		hc.CloseIdleConnections()
+		delete(cl.clients, scheme)
+	}
+	cl.tlsConfigs = make(map[string]*tls.Config)
+}
+
+func (s *poolStats) add(m map[string]int, addr string, n int) {
+	s.mu.Lock()
+	m[addr] += n
+	if m[addr] <= 0 {
+		delete(m, addr)
+	}
+	s.mu.Unlock()
+}
+
+// hostAddr to get the host address of the request with the default port of the scheme.
+func hostAddr(req *fasthttp.Request) string {
+	uri := req.URI()
+	host := string(uri.Host())
+	if _, _, e := net.SplitHostPort(host); e == nil {
+		return host
+	}
+
+	if string(uri.Scheme()) == "https" {
+		return net.JoinHostPort(host, "443")
+	}
+
+	return net.JoinHostPort(host, "80")
+}
+
+// trackedConn is a connection which is counted until it is closed.
+type trackedConn struct {
+	net.Conn
+	once  sync.Once
+	close func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.close)
+
+	return c.Conn.Close()
+}
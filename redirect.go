@@ -0,0 +1,218 @@
+package panggilhttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ErrUseLastResponse can be returned by a CheckRedirectFunc
+// to stop following the redirects and use the redirect response as the response.
+var ErrUseLastResponse = errors.New("use last response")
+
+// sensitiveHeaders is removed from the request when it is redirected to another host.
+var sensitiveHeaders = []string{
+	fasthttp.HeaderAuthorization,
+	fasthttp.HeaderProxyAuthorization,
+	fasthttp.HeaderCookie,
+	fasthttp.HeaderWWWAuthenticate,
+}
+
+// Redirect is a followed redirect response.
+type Redirect struct {
+	URL        string
+	Method     string
+	StatusCode int
+	// Location is the absolute URL of the next request.
+	Location string
+}
+
+// CheckRedirectFunc is called before following a redirect.
+// req is the next request, it can be modified, like to set a header.
+// via is the redirects so far, the last one is the redirect of req.
+// Return ErrUseLastResponse to use the redirect response as the response,
+// or an error to fail the HTTP call with a *RedirectError.
+type CheckRedirectFunc func(req *fasthttp.Request, via []Redirect) error
+
+// WithRedirects to follow max redirects for every HTTP call.
+// The default value is 0, the redirect response is returned without following.
+func (cl *Client) WithRedirects(max int) *Client {
+	if max < 0 {
+		cl.errs = append(cl.errs, ErrInvalidRedirects)
+
+		return cl
+	}
+
+	cl.maxRedirects = max
+
+	return cl
+}
+
+// WithCheckRedirect to check every redirect before following it for every HTTP call.
+func (cl *Client) WithCheckRedirect(fn CheckRedirectFunc) *Client {
+	cl.checkRedirect = fn
+
+	return cl
+}
+
+// WithRedirects to follow max redirects.
+// A 301 or 302 redirect of the POST method and a 303 redirect of every method except HEAD
+// is followed with the GET method without the body,
+// a 307 or 308 redirect is followed with the same method and body.
+// If the redirect is to another host, the credentials, the signature
+// and the sensitive headers like Authorization and Cookie are not sent.
+func (c *Config) WithRedirects(max int) *Config {
+	if max < 0 {
+		c.errs = append(c.errs, ErrInvalidRedirects)
+
+		return c
+	}
+
+	c.maxRedirects = max
+
+	return c
+}
+
+// WithCheckRedirect to check every redirect before following it.
+func (c *Config) WithCheckRedirect(fn CheckRedirectFunc) *Config {
+	c.checkRedirect = fn
+	c.callPolicy = true
+
+	return c
+}
+
+// follow to send the request and follow the redirects,
+// the followed redirects are set to the chain.
+func (c *Config) follow(ctx context.Context, row urlConfig, req *fasthttp.Request, resp *fasthttp.Response, deadline time.Time, chain *[]Redirect) error {
+	hopReq := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(hopReq)
+
+	req.CopyTo(hopReq)
+
+	origin := hopReq.URI()
+	originScheme := string(origin.Scheme())
+	originHost := hostname(string(origin.Host()))
+
+	trusted := true
+	redirects := make([]Redirect, 0)
+
+	for {
+		if e := c.sendHop(ctx, row, hopReq, resp, deadline, trusted); e != nil {
+			return e
+		}
+
+		location := resp.Header.Peek(fasthttp.HeaderLocation)
+		if !isRedirect(resp.StatusCode()) || len(location) == 0 {
+			*chain = redirects
+
+			return nil
+		}
+
+		current := hopReq.URI().String()
+		next, e := resolveLocation(current, string(location))
+		if e != nil {
+			return &RedirectError{URL: row.url, Method: row.method, Redirects: redirects, Err: e}
+		}
+
+		redirects = append(redirects, Redirect{
+			URL:        current,
+			Method:     string(hopReq.Header.Method()),
+			StatusCode: resp.StatusCode(),
+			Location:   next.String(),
+		})
+
+		if len(redirects) > c.maxRedirects {
+			return &RedirectError{URL: row.url, Method: row.method, Redirects: redirects, Err: ErrTooManyRedirects}
+		}
+
+		rewriteMethod(hopReq, resp.StatusCode())
+		hopReq.SetRequestURI(next.String())
+
+		// The credentials are never sent again after leaving the original host,
+		// or after downgrading from HTTPS to HTTP.
+		if trusted && (hostname(next.Host) != originHost || originScheme == "https" && next.Scheme != "https") {
+			trusted = false
+			for _, header := range sensitiveHeaders {
+				hopReq.Header.Del(header)
+			}
+			hopReq.Header.DelAllCookies()
+		}
+
+		if c.checkRedirect != nil {
+			if e := c.checkRedirect(hopReq, redirects); e == ErrUseLastResponse {
+				*chain = redirects[:len(redirects)-1]
+
+				return nil
+			} else if e != nil {
+				return &RedirectError{URL: row.url, Method: row.method, Redirects: redirects, Err: e}
+			}
+		}
+	}
+}
+
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case fasthttp.StatusMovedPermanently, fasthttp.StatusFound, fasthttp.StatusSeeOther,
+		fasthttp.StatusTemporaryRedirect, fasthttp.StatusPermanentRedirect:
+		return true
+	}
+
+	return false
+}
+
+// rewriteMethod to change the method and remove the body by the redirect status code.
+func rewriteMethod(req *fasthttp.Request, statusCode int) {
+	method := string(req.Header.Method())
+
+	switch statusCode {
+	case fasthttp.StatusMovedPermanently, fasthttp.StatusFound:
+		if method != fasthttp.MethodPost {
+			return
+		}
+	case fasthttp.StatusSeeOther:
+		if method == fasthttp.MethodGet || method == fasthttp.MethodHead {
+			return
+		}
+	default:
+		return
+	}
+
+	req.Header.SetMethod(fasthttp.MethodGet)
+	req.ResetBody()
+	req.Header.Del(fasthttp.HeaderContentType)
+	req.Header.Del(fasthttp.HeaderContentLength)
+	req.Header.Del(fasthttp.HeaderContentEncoding)
+	req.Header.Del("Content-Digest")
+}
+
+func resolveLocation(current, location string) (*url.URL, error) {
+	base, e := url.Parse(current)
+	if e != nil {
+		return nil, e
+	}
+
+	next, e := base.Parse(location)
+	if e != nil {
+		return nil, e
+	}
+
+	if next.Scheme != "http" && next.Scheme != "https" {
+		return nil, errors.New("unsupported redirect scheme " + next.Scheme)
+	}
+
+	return next, nil
+}
+
+// hostname to get the lowercase host without the port.
+func hostname(host string) string {
+	if h, _, e := net.SplitHostPort(host); e == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
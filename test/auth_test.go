@@ -0,0 +1,161 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestWithBasicAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "administrator" || password != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.New().
+		Get(ts.URL, nil, nil).
+		WithBasicAuth("administrator", "password").
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestWithBearerToken(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer 123456" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	// The middleware never sees the credentials.
+	var seen string
+	spy := func(next panggilhttp.Handler) panggilhttp.Handler {
+		return func(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error {
+			e := next(ctx, req, resp)
+			seen = string(req.Header.Peek("Authorization"))
+
+			return e
+		}
+	}
+
+	resp, e := panggilhttp.NewClient().
+		Use(spy).
+		WithBearerToken("123456").
+		R().
+		Get(ts.URL, nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "", seen)
+}
+
+func TestWithAPIKey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "header-key" || r.URL.Query().Get("api_key") != "query-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.New().
+		Get(ts.URL, nil, nil).
+		WithAPIKey("X-API-Key", "header-key", panggilhttp.APIKeyInHeader).
+		WithAPIKey("api_key", "query-key", panggilhttp.APIKeyInQuery).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestWithTokenSource(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"authorization":"` + r.Header.Get("Authorization") + `"}`))
+	}))
+	defer ts.Close()
+
+	var fetched int32
+	source := panggilhttp.TokenSourceFunc(func(ctx context.Context) (string, error) {
+		if atomic.AddInt32(&fetched, 1) > 1 {
+			return "", errors.New("token expired")
+		}
+
+		return "abcdef", nil
+	})
+
+	client := panggilhttp.NewClient().WithTokenSource(source)
+
+	resp, e := client.R().Get(ts.URL, nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.JSONEq(t, `{"authorization":"Bearer abcdef"}`, string(resp.Body))
+
+	// The failed token source is not retried.
+	_, e = client.R().Get(ts.URL, nil, nil).WithFailRetry(10, 1).Do()
+
+	var ae *panggilhttp.AuthError
+	assert.True(t, errors.As(e, &ae))
+	assert.Equal(t, ts.URL, ae.URL)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetched))
+}
+
+// failingSigner is a Signer which always fails.
+type failingSigner struct {
+	calls int32
+}
+
+func (s *failingSigner) Sign(req *fasthttp.Request) error {
+	atomic.AddInt32(&s.calls, 1)
+
+	return errors.New("no signing key")
+}
+
+func TestWithSignerError(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	signer := &failingSigner{}
+	_, e := panggilhttp.New().
+		Get(ts.URL, nil, nil).
+		WithSigner(signer).
+		WithFailRetry(10, 2).
+		Do()
+
+	var ae *panggilhttp.AuthError
+	assert.True(t, errors.As(e, &ae))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&signer.calls))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,229 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/breaker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreakerConsecutiveFailures(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		changes []string
+	)
+
+	b := breaker.New("localhost", &breaker.Config{
+		ConsecutiveFailures: 2,
+		Cooldown:            50 * time.Millisecond,
+		OnStateChange: func(key string, from, to breaker.State) {
+			mu.Lock()
+			changes = append(changes, key+":"+from.String()+"->"+to.String())
+			mu.Unlock()
+		},
+	})
+
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.NoError(t, b.Allow())
+	b.Success()
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, breaker.Closed, b.State())
+
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, breaker.Open, b.State())
+	assert.Equal(t, breaker.ErrCircuitOpen, b.Allow())
+
+	time.Sleep(60 * time.Millisecond)
+
+	// Only 1 trial request in the half-open state.
+	assert.NoError(t, b.Allow())
+	assert.Equal(t, breaker.ErrCircuitOpen, b.Allow())
+	b.Success()
+	assert.Equal(t, breaker.Closed, b.State())
+
+	assert.Equal(t, []string{
+		"localhost:closed->open",
+		"localhost:open->half-open",
+		"localhost:half-open->closed",
+	}, changes)
+}
+
+func TestBreakerFailureRate(t *testing.T) {
+	b := breaker.New("localhost", &breaker.Config{
+		FailureRate: 0.5,
+		MinRequests: 4,
+		Cooldown:    50 * time.Millisecond,
+	})
+
+	b.Success()
+	b.Failure()
+	b.Success()
+	assert.Equal(t, breaker.Closed, b.State())
+
+	b.Failure()
+	assert.Equal(t, breaker.Open, b.State())
+
+	time.Sleep(60 * time.Millisecond)
+
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, breaker.Open, b.State())
+}
+
+func TestBreakerFailureRateMinRequests(t *testing.T) {
+	b := breaker.New("localhost", &breaker.Config{FailureRate: 0.5})
+
+	// The failure rate is checked after 10 requests by default.
+	for i := 0; i < 9; i++ {
+		b.Failure()
+		assert.Equal(t, breaker.Closed, b.State())
+	}
+
+	b.Failure()
+	assert.Equal(t, breaker.Open, b.State())
+}
+
+func TestBreakerHalfOpenSuccesses(t *testing.T) {
+	b := breaker.New("localhost", &breaker.Config{
+		ConsecutiveFailures: 1,
+		Cooldown:            50 * time.Millisecond,
+		HalfOpenSuccesses:   2,
+	})
+
+	b.Failure()
+	assert.Equal(t, breaker.Open, b.State())
+
+	time.Sleep(60 * time.Millisecond)
+
+	// The trial requests are at least the successes to close the circuit.
+	assert.NoError(t, b.Allow())
+	assert.NoError(t, b.Allow())
+	assert.Equal(t, breaker.ErrCircuitOpen, b.Allow())
+
+	b.Success()
+	assert.Equal(t, breaker.HalfOpen, b.State())
+	b.Success()
+	assert.Equal(t, breaker.Closed, b.State())
+
+	b.Failure()
+	time.Sleep(60 * time.Millisecond)
+
+	assert.NoError(t, b.Allow())
+	b.Success()
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, breaker.Open, b.State())
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().
+		WithCircuitBreaker(&breaker.Config{
+			ConsecutiveFailures: 2,
+			Cooldown:            time.Minute,
+		})
+
+	for i := 0; i < 2; i++ {
+		resp, e := client.R().Get(ts.URL, nil, nil).Do()
+		assert.NoError(t, e)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	_, e := client.R().Get(ts.URL, nil, nil).Do()
+
+	var ce *panggilhttp.CircuitOpenError
+	assert.True(t, errors.As(e, &ce))
+	assert.True(t, errors.Is(e, panggilhttp.ErrCircuitOpen))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestBreakerCancel(t *testing.T) {
+	b := breaker.New("localhost", &breaker.Config{
+		ConsecutiveFailures: 1,
+		Cooldown:            50 * time.Millisecond,
+	})
+
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, breaker.Open, b.State())
+
+	time.Sleep(60 * time.Millisecond)
+
+	// The canceled trial request releases its slot without closing the circuit.
+	assert.NoError(t, b.Allow())
+	b.Cancel()
+	assert.Equal(t, breaker.HalfOpen, b.State())
+
+	assert.NoError(t, b.Allow())
+	assert.Equal(t, breaker.ErrCircuitOpen, b.Allow())
+	b.Success()
+	assert.Equal(t, breaker.Closed, b.State())
+}
+
+func TestWithCircuitBreakerCanceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer ts.Close()
+
+	var (
+		mu      sync.Mutex
+		changes []string
+	)
+	client := panggilhttp.NewClient().
+		WithCircuitBreaker(&breaker.Config{
+			ConsecutiveFailures: 1,
+			Cooldown:            50 * time.Millisecond,
+			OnStateChange: func(key string, from, to breaker.State) {
+				mu.Lock()
+				changes = append(changes, from.String()+"->"+to.String())
+				mu.Unlock()
+			},
+		})
+
+	_, e := client.R().Get(ts.URL+"/fail", nil, nil).Do()
+	assert.NoError(t, e)
+
+	time.Sleep(60 * time.Millisecond)
+
+	// The canceled trial request neither closes nor opens the circuit.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, e = client.R().Get(ts.URL+"/slow", nil, nil).DoContext(ctx)
+	assert.True(t, errors.Is(e, context.DeadlineExceeded))
+
+	mu.Lock()
+	assert.Equal(t, []string{"closed->open", "open->half-open"}, changes)
+	mu.Unlock()
+
+	resp, e := client.R().Get(ts.URL+"/ok", nil, nil).Do()
+	assert.NoError(t, e)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	mu.Lock()
+	assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->closed"}, changes)
+	mu.Unlock()
+}
@@ -0,0 +1,467 @@
+package test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/cache"
+	"github.com/KodepandaID/panggilhttp/pkg/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheMaxAge(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		switch r.URL.Path {
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/expires":
+			w.Header().Set("Expires", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		w.Write([]byte(`{"currency":"IDR"}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().WithCache(&cache.Config{})
+
+	tests := []struct {
+		path  string
+		calls int32
+	}{
+		{"/max-age", 1},
+		{"/no-store", 2},
+		{"/private", 1},
+		{"/expires", 1},
+	}
+
+	for _, tt := range tests {
+		atomic.StoreInt32(&calls, 0)
+
+		for i := 0; i < 2; i++ {
+			resp, e := client.R().Get(ts.URL+tt.path, nil, nil).Do()
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode, tt.path)
+			assert.Equal(t, `{"currency":"IDR"}`, string(resp.Body), tt.path)
+			if i == 1 && tt.calls == 1 {
+				assert.Equal(t, panggilhttp.CacheHit, resp.Cache, tt.path)
+				assert.Equal(t, "0", resp.Headers["Age"], tt.path)
+			}
+		}
+
+		assert.Equal(t, tt.calls, atomic.LoadInt32(&calls), tt.path)
+	}
+
+	// The request with no-cache is revalidated, the response has no validator.
+	atomic.StoreInt32(&calls, 0)
+	resp, e := client.R().Get(ts.URL+"/max-age", nil, nil).WithHeader(map[string]string{"Cache-Control": "no-cache"}).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, panggilhttp.CacheMiss, resp.Cache)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// A shared cache does not store the private response.
+	shared := panggilhttp.NewClient().WithCache(&cache.Config{Shared: true})
+	atomic.StoreInt32(&calls, 0)
+	for i := 0; i < 2; i++ {
+		if _, e := shared.R().Get(ts.URL+"/private", nil, nil).Do(); e != nil {
+			t.Fatal(e)
+		}
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	var calls, notModified int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.URL.Path == "/etag" {
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		} else {
+			w.Header().Set("Last-Modified", lastModified)
+			if r.Header.Get("If-Modified-Since") == lastModified {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		w.Write([]byte(`{"currency":"IDR"}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().WithCache(&cache.Config{})
+
+	for _, path := range []string{"/etag", "/last-modified"} {
+		atomic.StoreInt32(&calls, 0)
+		atomic.StoreInt32(&notModified, 0)
+
+		resp, e := client.R().Get(ts.URL+path, nil, nil).Do()
+		if e != nil {
+			t.Fatal(e)
+		}
+		assert.Equal(t, panggilhttp.CacheMiss, resp.Cache, path)
+
+		resp, e = client.R().Get(ts.URL+path, nil, nil).Do()
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		assert.Equal(t, `{"currency":"IDR"}`, string(resp.Body), path)
+		assert.Equal(t, panggilhttp.CacheRevalidated, resp.Cache, path)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls), path)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&notModified), path)
+	}
+}
+
+func TestCacheVaryAndInvalidate(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(`{"language":"` + r.Header.Get("Accept-Language") + `"}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().WithCache(&cache.Config{Store: cache.NewLRU(10)})
+	get := func(language string) panggilhttp.Response {
+		resp, e := client.R().Get(ts.URL, nil, nil).WithHeader(map[string]string{"Accept-Language": language}).Do()
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		return resp
+	}
+
+	assert.Equal(t, `{"language":"id"}`, string(get("id").Body))
+	assert.Equal(t, panggilhttp.CacheHit, get("id").Cache)
+	assert.Equal(t, `{"language":"en"}`, string(get("en").Body))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// The POST method invalidates the stored response.
+	if _, e := client.R().Post(ts.URL).Do(); e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, panggilhttp.CacheMiss, get("en").Cache)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestCacheStores(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer ts.Close()
+
+	dir, e := ioutil.TempDir("", "panggilhttp")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	disk, e := cache.NewDisk(dir)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// The stored response on the disk is shared by the clients.
+	for i := 0; i < 2; i++ {
+		resp, e := panggilhttp.NewClient().WithCache(&cache.Config{Store: disk}).R().Get(ts.URL+"/disk", nil, nil).Do()
+		if e != nil {
+			t.Fatal(e)
+		}
+		assert.Equal(t, `{"path":"/disk"}`, string(resp.Body))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// The least recently used response is removed.
+	lru := cache.NewLRU(1)
+	client := panggilhttp.NewClient().WithCache(&cache.Config{Store: lru})
+	for _, path := range []string{"/a", "/b", "/a"} {
+		if _, e := client.R().Get(ts.URL+path, nil, nil).Do(); e != nil {
+			t.Fatal(e)
+		}
+	}
+	assert.Equal(t, 1, lru.Len())
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		w.Write([]byte(`{"version":` + strconv.Itoa(int(n)) + `}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().WithCache(&cache.Config{})
+
+	resp, e := client.R().Get(ts.URL, nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, panggilhttp.CacheMiss, resp.Cache)
+
+	resp, e = client.R().Get(ts.URL, nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, panggilhttp.CacheStale, resp.Cache)
+	assert.Equal(t, `{"version":1}`, string(resp.Body))
+
+	// Wait for the background revalidation.
+	for i := 0; i < 100 && atomic.LoadInt32(&calls) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var body string
+	for i := 0; i < 100 && body != `{"version":2}`; i++ {
+		time.Sleep(10 * time.Millisecond)
+
+		resp, e = client.R().Get(ts.URL, nil, nil).Do()
+		if e != nil {
+			t.Fatal(e)
+		}
+		body = string(resp.Body)
+	}
+	assert.Equal(t, `{"version":2}`, body)
+	assert.Equal(t, panggilhttp.CacheStale, resp.Cache)
+}
+
+func TestCacheStaleWhileRevalidateReuse(t *testing.T) {
+	var calls int32
+	tenants := make(chan string, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		tenants <- r.Header.Get("X-Tenant")
+
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		w.Write([]byte(`{"currency":"IDR"}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().WithCache(&cache.Config{})
+	c := client.R().WithHeader(map[string]string{"X-Tenant": "a"}).Get(ts.URL, nil, nil)
+
+	for _, status := range []panggilhttp.CacheStatus{panggilhttp.CacheMiss, panggilhttp.CacheStale} {
+		resp, e := c.Do()
+		if e != nil {
+			t.Fatal(e)
+		}
+		assert.Equal(t, status, resp.Cache)
+	}
+
+	// The configuration is changed while it is revalidated in the background.
+	c.WithHeader(map[string]string{"X-Tenant": "b"}).
+		WithTimeout(2).
+		WithBearerToken("secret").
+		WithRetryPolicy(retry.Policy{Attempts: 1})
+
+	assert.Equal(t, "a", <-tenants)
+	assert.Equal(t, "a", <-tenants)
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	var failing int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/must-revalidate":
+			w.Header().Set("Cache-Control", "max-age=0, must-revalidate, stale-if-error=60")
+		case "/config":
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Header().Set("ETag", `"v1"`)
+		default:
+			w.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+		}
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().
+		WithCache(&cache.Config{StaleIfError: time.Minute}).
+		WithRetryPolicy(retry.Policy{
+			Attempts:    2,
+			Backoff:     retry.Constant{Interval: time.Millisecond},
+			StatusCodes: retry.DefaultStatusCodes,
+		})
+
+	for _, path := range []string{"/stale-if-error", "/must-revalidate", "/config"} {
+		if _, e := client.R().Get(ts.URL+path, nil, nil).Do(); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	atomic.StoreInt32(&failing, 1)
+
+	resp, e := client.R().
+		Get(ts.URL+"/stale-if-error", nil, nil).
+		Get(ts.URL+"/config", nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"path":"/config"}`, string(resp.Body))
+	for _, source := range resp.Sources {
+		assert.Equal(t, panggilhttp.CacheStale, source.Cache)
+		assert.Equal(t, 3, source.Attempts)
+	}
+
+	// must-revalidate prohibits serving the stale response.
+	resp, e = client.R().Get(ts.URL+"/must-revalidate", nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, panggilhttp.CacheMiss, resp.Cache)
+
+	// The server is down.
+	ts.Close()
+	resp, e = client.R().Get(ts.URL+"/stale-if-error", nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, panggilhttp.CacheStale, resp.Cache)
+	assert.Equal(t, `{"path":"/stale-if-error"}`, string(resp.Body))
+}
+
+func TestCacheCredentials(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		if r.URL.Path == "/public" {
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		w.Write([]byte(`{"authorization":"` + r.Header.Get("Authorization") + `","key":"` + r.Header.Get("X-API-Key") + `"}`))
+	}))
+	defer ts.Close()
+
+	for _, shared := range []bool{false, true} {
+		atomic.StoreInt32(&calls, 0)
+		client := panggilhttp.NewClient().WithCache(&cache.Config{Shared: shared})
+
+		for _, token := range []string{"alice", "bob"} {
+			for i := 0; i < 2; i++ {
+				resp, e := client.R().Get(ts.URL+"/private", nil, nil).WithBearerToken(token).Do()
+				assert.NoError(t, e)
+				assert.Equal(t, `{"authorization":"Bearer `+token+`","key":""}`, string(resp.Body))
+
+				// The shared cache never stores the response of a request with the Authorization header,
+				// unless the response is public.
+				if shared || i == 0 {
+					assert.Equal(t, panggilhttp.CacheMiss, resp.Cache)
+				} else {
+					assert.Equal(t, panggilhttp.CacheHit, resp.Cache)
+				}
+			}
+		}
+
+		expected := int32(2)
+		if shared {
+			expected = 4
+		}
+		assert.Equal(t, expected, atomic.LoadInt32(&calls))
+	}
+
+	// The response for the rotated token replaces the stored response of the URL,
+	// and an unsafe method invalidates it for any credentials.
+	atomic.StoreInt32(&calls, 0)
+	lru := cache.NewLRU(10)
+	client := panggilhttp.NewClient().WithCache(&cache.Config{Store: lru})
+	for _, token := range []string{"alice", "bob"} {
+		resp, e := client.R().Get(ts.URL+"/private", nil, nil).WithBearerToken(token).Do()
+		assert.NoError(t, e)
+		assert.Equal(t, panggilhttp.CacheMiss, resp.Cache)
+	}
+	assert.Equal(t, 1, lru.Len())
+
+	_, e := client.R().Post(ts.URL + "/private").Do()
+	assert.NoError(t, e)
+	assert.Equal(t, 0, lru.Len())
+
+	resp, e := client.R().Get(ts.URL+"/private", nil, nil).WithBearerToken("bob").Do()
+	assert.NoError(t, e)
+	assert.Equal(t, panggilhttp.CacheMiss, resp.Cache)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	// The credentials of the client are used to store the response.
+	atomic.StoreInt32(&calls, 0)
+	client = panggilhttp.NewClient().WithCache(&cache.Config{Shared: true}).WithBasicAuth("alice", "secret")
+	for i := 0; i < 2; i++ {
+		resp, e := client.R().Get(ts.URL+"/public", nil, nil).Do()
+		assert.NoError(t, e)
+		assert.Equal(t, `{"authorization":"Basic YWxpY2U6c2VjcmV0","key":""}`, string(resp.Body))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	resp, e = client.R().Get(ts.URL+"/public", nil, nil).WithBasicAuth("bob", "secret").Do()
+	assert.NoError(t, e)
+	assert.Equal(t, panggilhttp.CacheMiss, resp.Cache)
+	assert.Equal(t, `{"authorization":"Basic Ym9iOnNlY3JldA==","key":""}`, string(resp.Body))
+
+	// The API key in a header is never cached.
+	atomic.StoreInt32(&calls, 0)
+	client = panggilhttp.NewClient().WithCache(&cache.Config{})
+	for _, key := range []string{"alice", "bob"} {
+		resp, e := client.R().Get(ts.URL+"/private", nil, nil).WithAPIKey("X-API-Key", key, panggilhttp.APIKeyInHeader).Do()
+		assert.NoError(t, e)
+		assert.Equal(t, panggilhttp.CacheStatus(""), resp.Cache)
+		assert.Equal(t, `{"authorization":"","key":"`+key+`"}`, string(resp.Body))
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
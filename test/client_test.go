@@ -1,16 +1,22 @@
 package test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/KodepandaID/panggilhttp"
 	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
 )
 
 func TestMethodGET(t *testing.T) {
@@ -347,11 +353,14 @@ func TestRequestTimeout(t *testing.T) {
 
 	client := panggilhttp.New()
 
-	_, e := client.
+	resp, e := client.
 		Get(ts.URL, nil, nil).
 		Do()
 
-	assert.Equal(t, e.Error(), "Request Timeout")
+	var te *panggilhttp.TimeoutError
+	assert.True(t, errors.As(e, &te))
+	assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+	assert.Equal(t, 2, te.Attempts)
 }
 
 func TestWithHeader(t *testing.T) {
@@ -426,7 +435,9 @@ func TestWithTimeout(t *testing.T) {
 		Get(ts.URL, nil, nil).
 		Do()
 
-	assert.Equal(t, e.Error(), "Request Timeout")
+	var te *panggilhttp.TimeoutError
+	assert.True(t, errors.As(e, &te))
+	assert.True(t, errors.Is(e, fasthttp.ErrTimeout))
 }
 
 func TestRetryFail(t *testing.T) {
@@ -446,3 +457,380 @@ func TestRetryFail(t *testing.T) {
 
 	assert.Equal(t, 3, attempts)
 }
+
+func TestDoContextDeadline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, e := panggilhttp.New().
+		WithTimeout(5).
+		Get(ts.URL, nil, nil).
+		DoContext(ctx)
+
+	var ce *panggilhttp.CanceledError
+	assert.True(t, errors.As(e, &ce))
+	assert.True(t, errors.Is(e, context.DeadlineExceeded))
+	assert.Equal(t, ts.URL, ce.URL)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestDoContextCancelStopsRetry(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		time.Sleep(2 * time.Second)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(1500*time.Millisecond, cancel)
+
+	_, e := panggilhttp.New().
+		Get(ts.URL, nil, nil).
+		WithFailRetry(1000, 5).
+		DoContext(ctx)
+
+	assert.True(t, errors.Is(e, context.Canceled))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestMethodGETParallel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"source":"` + r.URL.Path + `","` + r.URL.Path[1:] + `":true}`))
+	}))
+	defer ts.Close()
+
+	start := time.Now()
+	resp, e := panggilhttp.New().
+		Get(ts.URL+"/a", nil, nil).
+		Get(ts.URL+"/b", nil, nil).
+		Get(ts.URL+"/c", nil, nil).
+		Get(ts.URL+"/d", nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	c := make(map[string]interface{})
+	json.Unmarshal(resp.Body, &c)
+
+	// The last URL wins for the same field, as the sequential merging.
+	assert.Equal(t, "/d", c["source"])
+	assert.Equal(t, true, c["a"])
+	assert.Equal(t, true, c["d"])
+	assert.Less(t, int64(time.Since(start)), int64(900*time.Millisecond))
+}
+
+func TestMethodNonGETSequential(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		mu.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"` + r.URL.Path[1:] + `":true}`))
+	}))
+	defer ts.Close()
+
+	_, e := panggilhttp.New().
+		Post(ts.URL+"/a").
+		Delete(ts.URL+"/b").
+		Get(ts.URL+"/c", nil, nil).
+		Get(ts.URL+"/d", nil, nil).
+		Put(ts.URL + "/e").
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// The GET calls are concurrent, between the other methods in the declared order.
+	assert.Len(t, calls, 5)
+	assert.Equal(t, []string{"POST /a", "DELETE /b"}, calls[:2])
+	assert.ElementsMatch(t, []string{"GET /c", "GET /d"}, calls[2:4])
+	assert.Equal(t, "PUT /e", calls[4])
+}
+
+func TestWithConcurrency(t *testing.T) {
+	var running, maxRunning int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message":"ping"}`))
+	}))
+	defer ts.Close()
+
+	_, e := panggilhttp.New().
+		Get(ts.URL+"/a", nil, nil).
+		Get(ts.URL+"/b", nil, nil).
+		Get(ts.URL+"/c", nil, nil).
+		Get(ts.URL+"/d", nil, nil).
+		WithConcurrency(2).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxRunning))
+}
+
+func TestClientReuse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Service") != "panggilhttp" || r.Header.Get("X-Request") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"request":"` + r.Header.Get("X-Request") + `"}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().
+		WithHeader(map[string]string{
+			"X-Service": "panggilhttp",
+		})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			id := strconv.Itoa(i)
+			resp, e := client.R().
+				WithHeader(map[string]string{
+					"X-Request": id,
+				}).
+				Get(ts.URL, nil, nil).
+				Do()
+			if e != nil {
+				t.Error(e)
+				return
+			}
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.JSONEq(t, `{"request":"`+id+`"}`, string(resp.Body))
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestDoTwice(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e := r.ParseMultipartForm(r.ContentLength); e != nil || r.FormValue("username") != "administrator" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	req := panggilhttp.NewClient().R().
+		Post(ts.URL).
+		SendFormData(map[string]string{
+			"username": "administrator",
+		})
+
+	for i := 0; i < 2; i++ {
+		resp, e := req.Do()
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := ts.URL
+	ts.Close()
+
+	_, e := panggilhttp.New().
+		Get(url, nil, nil).
+		WithFailRetry(10, 2).
+		Do()
+
+	var ce *panggilhttp.ConnectError
+	assert.True(t, errors.As(e, &ce))
+	assert.Equal(t, url, ce.URL)
+	assert.Equal(t, http.MethodGet, ce.Method)
+	assert.Equal(t, 3, ce.Attempts)
+}
+
+func TestDNSError(t *testing.T) {
+	_, e := panggilhttp.New().
+		Get("http://panggilhttp.invalid", nil, nil).
+		WithFailRetry(10, 1).
+		Do()
+
+	var de *panggilhttp.DNSError
+	assert.True(t, errors.As(e, &de))
+}
+
+func TestStatusError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`internal server error`))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message":"ping"}`))
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.New().
+		Get(ts.URL+"/down", nil, nil).
+		Get(ts.URL+"/up", nil, nil).
+		Do()
+
+	var se *panggilhttp.StatusError
+	assert.True(t, errors.As(e, &se))
+	assert.Equal(t, ts.URL+"/down", se.URL)
+	assert.Equal(t, http.StatusInternalServerError, se.StatusCode)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestMergeError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/html" {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`<html></html>`))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message":"ping"}`))
+	}))
+	defer ts.Close()
+
+	_, e := panggilhttp.New().
+		Get(ts.URL+"/json", nil, nil).
+		Get(ts.URL+"/html", []string{"message"}, nil).
+		Do()
+
+	var me *panggilhttp.MergeError
+	assert.True(t, errors.As(e, &me))
+	assert.Equal(t, ts.URL+"/html", me.URL)
+}
+
+func TestBuilderError(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer ts.Close()
+
+	_, e := panggilhttp.New().
+		Post(ts.URL).
+		WithTimeout(0).
+		WithFailRetry(0, 3).
+		SendJSON(map[string]interface{}{
+			"channel": make(chan int),
+		}).
+		SendFile("image", "person.jpg", nil).
+		Do()
+
+	var be *panggilhttp.BuilderError
+	assert.True(t, errors.As(e, &be))
+	assert.Equal(t, 4, len(be.Errs))
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidTimeout))
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidRetry))
+	assert.True(t, errors.Is(e, panggilhttp.ErrNilFile))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
+
+func TestClientBuilderError(t *testing.T) {
+	_, e := panggilhttp.NewClient().
+		WithTimeout(0).
+		R().
+		Get("http://localhost", nil, nil).
+		Do()
+
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidTimeout))
+}
+
+func TestNoURLError(t *testing.T) {
+	resp, e := panggilhttp.New().Do()
+
+	var be *panggilhttp.BuilderError
+	assert.True(t, errors.As(e, &be))
+	assert.Equal(t, []error{panggilhttp.ErrNoURL}, be.Errs)
+	assert.Equal(t, panggilhttp.Response{}, resp)
+
+	_, e = panggilhttp.New().WithTimeout(0).Do()
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidTimeout))
+	assert.True(t, errors.Is(e, panggilhttp.ErrNoURL))
+}
+
+func TestResponseSources(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Source", r.URL.Path)
+		http.SetCookie(w, &http.Cookie{Name: "source", Value: r.URL.Path[1:]})
+
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message":"ping"}`))
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.New().
+		Get(ts.URL+"/up", nil, nil).
+		Get(ts.URL+"/down", nil, nil).
+		Get(ts.URL+"/last", nil, nil).
+		Do()
+
+	var se *panggilhttp.StatusError
+	assert.True(t, errors.As(e, &se))
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, 3, len(resp.Sources))
+
+	assert.Equal(t, ts.URL+"/up", resp.Sources[0].URL)
+	assert.Equal(t, http.MethodGet, resp.Sources[0].Method)
+	assert.Equal(t, http.StatusOK, resp.Sources[0].StatusCode)
+	assert.Equal(t, "/up", resp.Sources[0].Headers["X-Source"])
+	assert.Contains(t, resp.Sources[0].Cookies["source"], "source=up")
+	assert.Equal(t, `{"message":"ping"}`, string(resp.Sources[0].Body))
+	assert.NoError(t, resp.Sources[0].Err)
+	assert.Greater(t, int64(resp.Sources[0].Duration), int64(0))
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Sources[1].StatusCode)
+	assert.True(t, errors.As(resp.Sources[1].Err, &se))
+
+	assert.Equal(t, http.StatusOK, resp.Sources[2].StatusCode)
+	assert.NoError(t, resp.Sources[2].Err)
+}
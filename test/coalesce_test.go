@@ -0,0 +1,252 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCoalescing(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Tenant", r.Header.Get("X-Tenant"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"name": "panggilhttp", "version": 1}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().WithTimeout(5).WithCoalescing()
+
+	var wg sync.WaitGroup
+	var shared int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			c := client.R()
+			if i%2 == 0 {
+				c.Get(ts.URL, []string{"name"}, nil)
+			} else {
+				c.Get(ts.URL, nil, []string{"name"})
+			}
+
+			resp, e := c.Do()
+			assert.NoError(t, e)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			if resp.Sources[0].Coalesced {
+				atomic.AddInt32(&shared, 1)
+			}
+
+			// Every caller gets its own copy of the response.
+			resp.Headers["X-Tenant"] = "changed"
+			resp.Sources[0].Body[0] = 'x'
+
+			var body map[string]interface{}
+			assert.NoError(t, json.Unmarshal(resp.Body, &body))
+			if i%2 == 0 {
+				assert.Equal(t, map[string]interface{}{"name": "panggilhttp"}, body)
+			} else {
+				assert.Equal(t, map[string]interface{}{"version": float64(1)}, body)
+			}
+		}(i)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(50), atomic.LoadInt32(&shared))
+
+	// The next HTTP call is not in-flight anymore, so it calls the HTTP.
+	resp, e := client.R().Get(ts.URL, nil, nil).Do()
+	assert.NoError(t, e)
+	assert.False(t, resp.Sources[0].Coalesced)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestWithCoalescingSignificantHeaders(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Tenant", r.Header.Get("X-Tenant"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"name": "panggilhttp", "version": 1}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().WithTimeout(5).WithCoalescing("X-Tenant")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			tenant := "a"
+			if i%2 == 1 {
+				tenant = "b"
+			}
+
+			resp, e := client.R().
+				WithHeader(map[string]string{"X-Tenant": tenant, "X-Request-Id": string(rune('a' + i))}).
+				Get(ts.URL, nil, nil).
+				Do()
+			assert.NoError(t, e)
+			assert.Equal(t, tenant, resp.Headers["X-Tenant"])
+		}(i)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// The X-Request-Id header is not significant, so there is 1 HTTP call for every tenant.
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestWithCoalescingNotShared(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"name": "panggilhttp", "version": 1}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().WithTimeout(5).WithCoalescing()
+
+	var wg sync.WaitGroup
+	calling := []func() *panggilhttp.Config{
+		func() *panggilhttp.Config { return client.R().Get(ts.URL, nil, nil) },
+		func() *panggilhttp.Config { return client.R().Get(ts.URL, nil, nil).WithBearerToken("secret") },
+		func() *panggilhttp.Config { return client.R().Get(ts.URL, nil, nil).WithBasicAuth("user", "secret") },
+		func() *panggilhttp.Config {
+			return client.R().WithHeader(map[string]string{"Authorization": "Bearer other"}).Get(ts.URL, nil, nil)
+		},
+		func() *panggilhttp.Config { return client.R().Post(ts.URL) },
+		func() *panggilhttp.Config { return client.R().Get(ts.URL, nil, nil).WithRedirects(5) },
+		func() *panggilhttp.Config { return client.R().Get(ts.URL, nil, nil).WithTimeout(3) },
+		func() *panggilhttp.Config {
+			return client.R().Get(ts.URL, nil, nil).WithRetryPolicy(retry.Policy{Attempts: 1})
+		},
+	}
+	for _, fn := range calling {
+		wg.Add(1)
+		go func(c *panggilhttp.Config) {
+			defer wg.Done()
+
+			resp, e := c.Do()
+			assert.NoError(t, e)
+			assert.False(t, resp.Sources[0].Coalesced)
+		}(fn())
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(8), atomic.LoadInt32(&calls))
+}
+
+func TestWithCoalescingContextCanceled(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"name": "panggilhttp", "version": 1}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().WithTimeout(5).WithCoalescing()
+
+	done := make(chan panggilhttp.Response)
+	go func() {
+		resp, e := client.R().Get(ts.URL, nil, nil).Do()
+		assert.NoError(t, e)
+		done <- resp
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// The caller stops waiting, but the shared HTTP call is not canceled.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, e := client.R().Get(ts.URL, nil, nil).DoContext(ctx)
+	assert.True(t, errors.Is(e, context.DeadlineExceeded))
+
+	close(release)
+	resp := <-done
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestWithCoalescingRedirects(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/target" {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"message": "redirected"}`))
+
+			return
+		}
+
+		<-release
+		http.Redirect(w, r, "/target", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().WithTimeout(5).WithCoalescing()
+
+	var wg sync.WaitGroup
+	for _, max := range []int{0, 5} {
+		wg.Add(1)
+		go func(max int) {
+			defer wg.Done()
+
+			resp, e := client.R().Get(ts.URL+"/redirect", nil, nil).WithRedirects(max).Do()
+			assert.NoError(t, e)
+			assert.False(t, resp.Sources[0].Coalesced)
+
+			if max == 0 {
+				assert.Equal(t, http.StatusFound, resp.StatusCode)
+			} else {
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Len(t, resp.Redirects, 1)
+			}
+		}(max)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
@@ -0,0 +1,224 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func encodeBody(t *testing.T, encoding string, body []byte) []byte {
+	switch encoding {
+	case panggilhttp.EncodingGzip:
+		return fasthttp.AppendGzipBytes(nil, body)
+	case panggilhttp.EncodingDeflate:
+		return fasthttp.AppendDeflateBytes(nil, body)
+	case panggilhttp.EncodingBrotli:
+		return fasthttp.AppendBrotliBytes(nil, body)
+	case panggilhttp.EncodingZstd:
+		enc, e := zstd.NewWriter(nil)
+		assert.NoError(t, e)
+
+		return enc.EncodeAll(body, nil)
+	}
+
+	return body
+}
+
+func decodeBody(t *testing.T, encoding string, body []byte) []byte {
+	var (
+		data []byte
+		e    error
+	)
+
+	switch encoding {
+	case panggilhttp.EncodingGzip:
+		data, e = fasthttp.AppendGunzipBytes(nil, body)
+	case panggilhttp.EncodingDeflate:
+		data, e = fasthttp.AppendInflateBytes(nil, body)
+	case panggilhttp.EncodingBrotli:
+		data, e = fasthttp.AppendUnbrotliBytes(nil, body)
+	case panggilhttp.EncodingZstd:
+		dec, err := zstd.NewReader(nil)
+		assert.NoError(t, err)
+		data, e = dec.DecodeAll(body, nil)
+	default:
+		data = body
+	}
+	assert.NoError(t, e)
+
+	return data
+}
+
+func TestResponseDecompression(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip, deflate, br, zstd", r.Header.Get("Accept-Encoding"))
+
+		encoding := strings.TrimPrefix(r.URL.Path, "/")
+		body := []byte(`{"encoding": "` + encoding + `", "` + encoding + `": true}`)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", encoding)
+		w.WriteHeader(http.StatusOK)
+		w.Write(encodeBody(t, encoding, body))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient()
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		resp, e := client.R().Get(ts.URL+"/"+encoding, nil, nil).Do()
+		assert.NoError(t, e)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Headers["Content-Encoding"])
+		assert.JSONEq(t, `{"encoding": "`+encoding+`", "`+encoding+`": true}`, string(resp.Body))
+	}
+
+	// The response body of every URL is decoded before merging.
+	resp, e := client.R().
+		Get(ts.URL+"/gzip", []string{"gzip"}, nil).
+		Get(ts.URL+"/br", nil, []string{"encoding"}).
+		Get(ts.URL+"/zstd", []string{"zstd"}, nil).
+		Do()
+	assert.NoError(t, e)
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(resp.Body, &body))
+	assert.Equal(t, map[string]interface{}{"gzip": true, "br": true, "zstd": true}, body)
+}
+
+func TestResponseDecompressionAcceptEncoding(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusOK)
+		w.Write(encodeBody(t, "gzip", []byte(`{"message": "hello"}`)))
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.New().
+		WithHeader(map[string]string{"Accept-Encoding": "gzip"}).
+		Get(ts.URL, nil, nil).
+		Do()
+	assert.NoError(t, e)
+	assert.JSONEq(t, `{"message": "hello"}`, string(resp.Body))
+}
+
+func TestResponseDecompressionError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/unsupported" {
+			w.Header().Set("Content-Encoding", "compress")
+		} else {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message": "not compressed"}`))
+	}))
+	defer ts.Close()
+
+	_, e := panggilhttp.New().Get(ts.URL+"/gzip", nil, nil).Do()
+
+	var decodeErr *panggilhttp.DecodeError
+	assert.True(t, errors.As(e, &decodeErr))
+	assert.Equal(t, "gzip", decodeErr.Encoding)
+
+	// The response body with an unsupported content encoding is not decoded.
+	resp, e := panggilhttp.New().Get(ts.URL+"/unsupported", nil, nil).Do()
+	assert.NoError(t, e)
+	assert.Equal(t, "compress", resp.Headers["Content-Encoding"])
+	assert.Equal(t, `{"message": "not compressed"}`, string(resp.Body))
+}
+
+func TestWithRequestCompression(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, e := ioutil.ReadAll(r.Body)
+		assert.NoError(t, e)
+
+		encoding := r.Header.Get("Content-Encoding")
+		body = decodeBody(t, encoding, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Encoding", encoding)
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	data := map[string]interface{}{"message": strings.Repeat("panggilhttp ", 200)}
+
+	client := panggilhttp.NewClient().WithRequestCompression(panggilhttp.EncodingGzip, 0)
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		resp, e := client.R().
+			Post(ts.URL).
+			WithRequestCompression(encoding, 0).
+			SendJSON(data).
+			Do()
+		assert.NoError(t, e)
+		assert.Equal(t, encoding, resp.Headers["X-Content-Encoding"])
+
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(resp.Body, &body))
+		assert.Equal(t, data, body)
+	}
+
+	// The request body less than the min size is not compressed.
+	resp, e := client.R().Post(ts.URL).SendJSON(map[string]interface{}{"message": "hello"}).Do()
+	assert.NoError(t, e)
+	assert.Empty(t, resp.Headers["X-Content-Encoding"])
+	assert.JSONEq(t, `{"message": "hello"}`, string(resp.Body))
+
+	// The form data is never compressed.
+	resp, e = client.R().Post(ts.URL).SendFormData(map[string]string{"message": strings.Repeat("panggilhttp ", 200)}).Do()
+	assert.NoError(t, e)
+	assert.Empty(t, resp.Headers["X-Content-Encoding"])
+
+	_, e = panggilhttp.New().Post(ts.URL).WithRequestCompression("compress", 0).SendJSON(data).Do()
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidEncoding))
+}
+
+func TestResponseDecompressionLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		encoding := strings.TrimPrefix(r.URL.Path, "/")
+		w.Header().Set("Content-Encoding", encoding)
+		w.WriteHeader(http.StatusOK)
+		w.Write(encodeBody(t, encoding, make([]byte, 2<<20)))
+	}))
+	defer ts.Close()
+
+	// The empty response body is not decoded.
+	resp, e := panggilhttp.New().Delete(ts.URL).Do()
+	assert.NoError(t, e)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Empty(t, resp.Body)
+
+	client := panggilhttp.NewClient().WithMaxResponseBodySize(1 << 20)
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		_, e = client.R().Get(ts.URL+"/"+encoding, nil, nil).Do()
+
+		var decodeErr *panggilhttp.DecodeError
+		assert.True(t, errors.As(e, &decodeErr), encoding)
+		assert.True(t, errors.Is(e, panggilhttp.ErrBodyTooLarge), encoding)
+	}
+
+	resp, e = panggilhttp.NewClient().WithMaxResponseBodySize(4<<20).R().Get(ts.URL+"/gzip", nil, nil).Do()
+	assert.NoError(t, e)
+	assert.Len(t, resp.Body, 2<<20)
+
+	_, e = panggilhttp.NewClient().WithMaxResponseBodySize(-1).R().Get(ts.URL+"/gzip", nil, nil).Do()
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidBodySize))
+}
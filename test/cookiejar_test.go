@@ -0,0 +1,149 @@
+package test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+)
+
+func cookieNames(cookies []cookiejar.Cookie) []string {
+	names := make([]string, 0, len(cookies))
+	for _, cookie := range cookies {
+		names = append(names, cookie.Name)
+	}
+
+	return names
+}
+
+func TestWithCookieJar(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "123456", Path: "/", HttpOnly: true})
+			w.WriteHeader(http.StatusOK)
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "", Path: "/", MaxAge: -1})
+			w.WriteHeader(http.StatusOK)
+		default:
+			cookie, e := r.Cookie("session")
+			if e != nil || cookie.Value != "123456" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			w.Write([]byte(`{"username":"administrator"}`))
+		}
+	}))
+	defer ts.Close()
+
+	jar := cookiejar.New(nil)
+	client := panggilhttp.NewClient().WithCookieJar(jar)
+
+	resp, e := client.R().Get(ts.URL+"/profile", nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	if _, e := client.R().Post(ts.URL + "/login").Do(); e != nil {
+		t.Fatal(e)
+	}
+
+	resp, e = client.R().Get(ts.URL+"/profile", nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"username":"administrator"}`, string(resp.Body))
+
+	// The cookie set by WithCookie has precedence.
+	resp, e = client.R().Get(ts.URL+"/profile", nil, nil).WithCookie(map[string]string{"session": "654321"}).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	if _, e := client.R().Post(ts.URL + "/logout").Do(); e != nil {
+		t.Fatal(e)
+	}
+	assert.Len(t, jar.All(), 0)
+}
+
+func TestCookieJarDomainAndPath(t *testing.T) {
+	jar := cookiejar.New(nil)
+
+	u, _ := url.Parse("http://www.example.com/account/login")
+	jar.SetCookies(u, []string{
+		"host=1",
+		"domain=1; Domain=.example.com; Path=/",
+		"account=1; Path=/account",
+		"secure=1; Path=/; Secure",
+		"foreign=1; Domain=example.org",
+		"suffix=1; Domain=com",
+		"expired=1; Expires=Thu, 01 Jan 1970 00:00:00 GMT",
+	})
+
+	u, _ = url.Parse("http://www.example.com/account/profile")
+	assert.Equal(t, []string{"account", "host", "domain"}, cookieNames(jar.Cookies(u)))
+
+	u, _ = url.Parse("https://www.example.com/accounts")
+	assert.ElementsMatch(t, []string{"domain", "secure"}, cookieNames(jar.Cookies(u)))
+
+	u, _ = url.Parse("http://api.example.com/account")
+	assert.Equal(t, []string{"domain"}, cookieNames(jar.Cookies(u)))
+
+	u, _ = url.Parse("http://example.org/")
+	assert.Len(t, jar.Cookies(u), 0)
+
+	// Max-Age has precedence over Expires.
+	u, _ = url.Parse("http://www.example.com/")
+	jar.SetCookies(u, []string{"domain=2; Domain=example.com; Path=/; Max-Age=0; Expires=Fri, 01 Jan 2100 00:00:00 GMT"})
+	u, _ = url.Parse("http://api.example.com/")
+	assert.Len(t, jar.Cookies(u), 0)
+}
+
+func TestCookieJarSaveAndLoad(t *testing.T) {
+	dir, e := ioutil.TempDir("", "panggilhttp")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "cookies.json")
+
+	u, _ := url.Parse("http://www.example.com/")
+	jar := cookiejar.New(nil)
+	jar.SetCookies(u, []string{
+		"session=123456; HttpOnly",
+		"remember=1; Max-Age=3600",
+	})
+
+	if e := jar.Save(filename); e != nil {
+		t.Fatal(e)
+	}
+
+	info, e := os.Stat(filename)
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded := cookiejar.New(nil)
+	if e := loaded.Load(filename); e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, []string{"remember", "session"}, cookieNames(loaded.All()))
+	assert.ElementsMatch(t, []string{"remember", "session"}, cookieNames(loaded.Cookies(u)))
+
+	u, _ = url.Parse("http://api.example.com/")
+	assert.Len(t, loaded.Cookies(u), 0)
+}
@@ -62,3 +62,11 @@ func TestMergeDataWithWishlistAndBlacklist(t *testing.T) {
 	assert.Equal(t, 1034, h.DestinationID)
 	assert.Equal(t, 3, len(h.Destinations))
 }
+
+func TestMergeInvalidJSON(t *testing.T) {
+	m := merging.New()
+
+	assert.Error(t, m.Merge(nil, []byte(`<html></html>`)))
+	assert.Error(t, m.MergeFromWhitelist([]string{"id"}, []byte(`[1, 2]`)))
+	assert.NoError(t, m.MergeFromWhitelist([]string{"missing"}, []byte(`{"id": 1}`)))
+}
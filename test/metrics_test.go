@@ -0,0 +1,302 @@
+package test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/cache"
+	"github.com/KodepandaID/panggilhttp/pkg/metrics"
+	"github.com/KodepandaID/panggilhttp/pkg/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a MetricsCollector to record every metrics.
+type recorder struct {
+	mu       sync.Mutex
+	requests []metrics.Request
+	retries  []metrics.Retry
+	merges   []metrics.Merge
+}
+
+func (r *recorder) ObserveRequest(m metrics.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, m)
+}
+
+func (r *recorder) ObserveRetry(m metrics.Retry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries = append(r.retries, m)
+}
+
+func (r *recorder) ObserveMerge(m metrics.Merge) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.merges = append(r.merges, m)
+}
+
+func TestWithMetrics(t *testing.T) {
+	var flaky int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if atomic.AddInt32(&flaky, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+		case "/echo":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write(body)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"path": "` + r.URL.Path + `"}`))
+	}))
+	defer ts.Close()
+
+	host := ts.Listener.Addr().String()
+	rec := &recorder{}
+	client := panggilhttp.NewClient().
+		WithMetrics(rec).
+		WithRetryPolicy(retry.Policy{
+			Attempts:    2,
+			Backoff:     retry.Constant{Interval: time.Millisecond},
+			StatusCodes: retry.DefaultStatusCodes,
+		})
+
+	resp, e := client.R().Get(ts.URL+"/flaky", nil, nil).Get(ts.URL+"/ok", []string{"path"}, nil).Do()
+	assert.NoError(t, e)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, e = client.R().Post(ts.URL + "/echo").SendJSON(map[string]interface{}{"message": "hello"}).Do()
+	assert.NoError(t, e)
+
+	assert.Len(t, rec.requests, 3)
+	// The GET calls run concurrently, so they are observed in any order.
+	sort.Slice(rec.requests[:2], func(i, j int) bool { return rec.requests[i].Attempts > rec.requests[j].Attempts })
+	assert.Equal(t, metrics.Request{
+		Method:        http.MethodGet,
+		Host:          host,
+		Source:        metrics.SourceUpstream,
+		StatusCode:    http.StatusOK,
+		Duration:      rec.requests[0].Duration,
+		Attempts:      2,
+		BytesReceived: len(`{"path": "/flaky"}`),
+	}, rec.requests[0])
+	assert.Equal(t, 1, rec.requests[1].Attempts)
+	assert.Equal(t, http.StatusCreated, rec.requests[2].StatusCode)
+	assert.Equal(t, len(`{"message":"hello"}`), rec.requests[2].BytesSent)
+	assert.Equal(t, len(`{"message":"hello"}`), rec.requests[2].BytesReceived)
+
+	assert.Equal(t, []metrics.Retry{{
+		Method:     http.MethodGet,
+		Host:       host,
+		Attempt:    1,
+		Reason:     metrics.ReasonStatus,
+		StatusCode: http.StatusServiceUnavailable,
+		Wait:       time.Millisecond,
+	}}, rec.retries)
+
+	assert.Len(t, rec.merges, 1)
+	assert.Equal(t, 2, rec.merges[0].Sources)
+	assert.NoError(t, rec.merges[0].Err)
+}
+
+func TestWithMetricsTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(1500 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	rec := &recorder{}
+	client := panggilhttp.NewClient().WithMetrics(rec).WithTimeout(1)
+
+	_, e := client.R().
+		WithRetryPolicy(retry.Policy{Attempts: 1, Backoff: retry.Constant{Interval: time.Millisecond}}).
+		Get(ts.URL+"/slow", nil, nil).
+		Do()
+	assert.Error(t, e)
+
+	assert.Len(t, rec.requests, 1)
+	assert.True(t, rec.requests[0].Timeout)
+	assert.Equal(t, 0, rec.requests[0].StatusCode)
+	assert.Equal(t, 2, rec.requests[0].Attempts)
+
+	assert.Len(t, rec.retries, 1)
+	assert.Equal(t, metrics.ReasonTimeout, rec.retries[0].Reason)
+}
+
+func TestWithMetricsSource(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/shared" {
+			<-release
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"name": "panggilhttp"}`))
+	}))
+	defer ts.Close()
+
+	rec := &recorder{}
+	exporter := metrics.NewPrometheus(nil)
+	client := panggilhttp.NewClient().WithMetrics(rec).WithCache(&cache.Config{})
+
+	for i := 0; i < 2; i++ {
+		_, e := client.R().Get(ts.URL+"/cached", nil, nil).Do()
+		assert.NoError(t, e)
+	}
+
+	assert.Len(t, rec.requests, 2)
+	assert.Equal(t, metrics.SourceUpstream, rec.requests[0].Source)
+	assert.Equal(t, metrics.SourceCache, rec.requests[1].Source)
+	assert.Equal(t, 0, rec.requests[1].Attempts)
+
+	rec = &recorder{}
+	client = panggilhttp.NewClient().WithTimeout(5).WithMetrics(rec).WithCoalescing()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, e := client.R().Get(ts.URL+"/shared", nil, nil).Do()
+			assert.NoError(t, e)
+		}()
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// The upstream HTTP call is observed once, and every caller is observed as coalesced.
+	assert.Len(t, rec.requests, 11)
+	sources := make(map[string]int)
+	for _, r := range rec.requests {
+		sources[r.Source]++
+		exporter.ObserveRequest(r)
+	}
+	assert.Equal(t, map[string]int{metrics.SourceUpstream: 1, metrics.SourceCoalesced: 10}, sources)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	srv := httptest.NewServer(exporter)
+	defer srv.Close()
+
+	resp, e := http.Get(srv.URL)
+	assert.NoError(t, e)
+	defer resp.Body.Close()
+
+	body, e := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, e)
+
+	host := ts.Listener.Addr().String()
+	assert.Contains(t, string(body), `panggilhttp_requests_total{method="GET",host="`+host+`",status="200"} 1`)
+	assert.Contains(t, string(body), `panggilhttp_served_total{method="GET",host="`+host+`",source="coalesced"} 10`)
+}
+
+func TestPrometheusExporter(t *testing.T) {
+	var flaky int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if atomic.AddInt32(&flaky, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+		case "/echo":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write(body)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"path": "` + r.URL.Path + `"}`))
+	}))
+	defer ts.Close()
+
+	host := ts.Listener.Addr().String()
+	exporter := metrics.NewPrometheus(&metrics.PrometheusConfig{Buckets: []float64{1, 0.5}})
+	client := panggilhttp.NewClient().
+		WithMetrics(exporter).
+		WithRetryPolicy(retry.Policy{
+			Attempts:    2,
+			Backoff:     retry.Constant{Interval: time.Millisecond},
+			StatusCodes: retry.DefaultStatusCodes,
+		})
+
+	_, e := client.R().Get(ts.URL+"/flaky", nil, nil).Get(ts.URL+"/ok", nil, nil).Do()
+	assert.NoError(t, e)
+	_, e = client.R().Post(ts.URL + "/echo").SendJSON(map[string]interface{}{"message": "hello"}).Do()
+	assert.NoError(t, e)
+
+	exporter.ObserveRequest(metrics.Request{Method: "GET", Host: `a"b\c`, Timeout: true})
+
+	srv := httptest.NewServer(exporter)
+	defer srv.Close()
+
+	resp, e := http.Get(srv.URL)
+	assert.NoError(t, e)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	body, e := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, e)
+
+	text := string(body)
+	for _, line := range []string{
+		"# TYPE panggilhttp_requests_total counter",
+		`panggilhttp_requests_total{method="GET",host="` + host + `",status="200"} 2`,
+		`panggilhttp_requests_total{method="POST",host="` + host + `",status="201"} 1`,
+		`panggilhttp_requests_total{method="GET",host="a\"b\\c",status="error"} 1`,
+		"# TYPE panggilhttp_request_duration_seconds histogram",
+		`panggilhttp_request_duration_seconds_bucket{method="GET",host="` + host + `",le="0.5"} 2`,
+		`panggilhttp_request_duration_seconds_bucket{method="GET",host="` + host + `",le="1"} 2`,
+		`panggilhttp_request_duration_seconds_bucket{method="GET",host="` + host + `",le="+Inf"} 2`,
+		`panggilhttp_request_duration_seconds_count{method="GET",host="` + host + `"} 2`,
+		`panggilhttp_retries_total{method="GET",host="` + host + `",reason="status"} 1`,
+		`panggilhttp_timeouts_total{method="GET",host="a\"b\\c"} 1`,
+		`panggilhttp_request_bytes_total{method="POST",host="` + host + `"} 19`,
+		`panggilhttp_response_bytes_total{method="POST",host="` + host + `"} 19`,
+		`panggilhttp_merge_duration_seconds_bucket{le="+Inf"} 1`,
+		"panggilhttp_merge_duration_seconds_count 1",
+		"panggilhttp_merge_errors_total 0",
+	} {
+		assert.Contains(t, text, line+"\n")
+	}
+
+	// Every line is a comment or a sample.
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		assert.True(t, strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "panggilhttp_"), line)
+	}
+
+	var buf bytes.Buffer
+	n, e := exporter.WriteTo(&buf)
+	assert.NoError(t, e)
+	assert.Equal(t, int64(buf.Len()), n)
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"trace":"` + r.Header.Get("X-Trace") + `"}`))
+	}))
+	defer ts.Close()
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+
+	trace := func(name string) panggilhttp.Middleware {
+		return func(next panggilhttp.Handler) panggilhttp.Handler {
+			return func(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error {
+				mu.Lock()
+				order = append(order, "before "+name)
+				mu.Unlock()
+
+				req.Header.Set("X-Trace", string(req.Header.Peek("X-Trace"))+name)
+				e := next(ctx, req, resp)
+
+				mu.Lock()
+				order = append(order, "after "+name)
+				mu.Unlock()
+
+				return e
+			}
+		}
+	}
+
+	resp, e := panggilhttp.NewClient().
+		Use(trace("a"), trace("b")).
+		R().
+		Get(ts.URL, nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.JSONEq(t, `{"trace":"ab"}`, string(resp.Body))
+	assert.Equal(t, []string{"before a", "before b", "after b", "after a"}, order)
+}
+
+func TestMiddlewareEveryAttempt(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	var attempts int32
+	count := func(next panggilhttp.Handler) panggilhttp.Handler {
+		return func(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error {
+			atomic.AddInt32(&attempts, 1)
+
+			return next(ctx, req, resp)
+		}
+	}
+
+	_, e := panggilhttp.NewClient().
+		Use(count).
+		R().
+		Get(ts.URL, nil, nil).
+		WithRetryPolicy(retry.Policy{
+			Attempts:    2,
+			Backoff:     retry.Constant{Interval: 10 * time.Millisecond},
+			StatusCodes: retry.DefaultStatusCodes,
+		}).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer ts.Close()
+
+	mock := func(next panggilhttp.Handler) panggilhttp.Handler {
+		return func(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error {
+			resp.SetStatusCode(http.StatusTeapot)
+			resp.SetBodyString(`{"message":"mocked"}`)
+
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	resp, e := panggilhttp.NewClient().
+		Use(mock).
+		R().
+		Get(ts.URL, nil, nil).
+		DoContext(ctx)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	assert.JSONEq(t, `{"message":"mocked"}`, string(resp.Body))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
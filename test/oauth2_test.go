@@ -0,0 +1,181 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/oauth2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuth2TokenCache(t *testing.T) {
+	var issued int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != "client" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Slow token endpoint, to check the concurrent callers share 1 token request.
+		time.Sleep(50 * time.Millisecond)
+
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fmt.Sprintf(`{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, n)))
+	}))
+	defer ts.Close()
+
+	source := oauth2.New(&oauth2.Config{
+		TokenURL:     ts.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			token, e := source.Token(context.Background())
+			assert.NoError(t, e)
+			assert.Equal(t, "token-1", token)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&issued))
+
+	source.InvalidateToken("token-1")
+	token, e := source.Token(context.Background())
+	assert.NoError(t, e)
+	assert.Equal(t, "token-2", token)
+}
+
+func TestOAuth2TokenRefresh(t *testing.T) {
+	var issued int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != "client" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fmt.Sprintf(`{"access_token":"token-%d","token_type":"Bearer","expires_in":1}`, n)))
+	}))
+	defer ts.Close()
+
+	// The token is expired in 1 second, but it is refreshed 10 seconds before expired.
+	source := oauth2.New(&oauth2.Config{
+		TokenURL:     ts.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	})
+
+	first, _ := source.Token(context.Background())
+	second, _ := source.Token(context.Background())
+	assert.Equal(t, "token-1", first)
+	assert.Equal(t, "token-2", second)
+}
+
+func TestOAuth2RetrieveError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+	}))
+	defer ts.Close()
+
+	source := oauth2.New(&oauth2.Config{
+		TokenURL:     ts.URL,
+		ClientID:     "client",
+		ClientSecret: "wrong",
+	})
+
+	_, e := source.Token(context.Background())
+
+	var re *oauth2.RetrieveError
+	assert.True(t, errors.As(e, &re))
+	assert.Equal(t, http.StatusUnauthorized, re.StatusCode)
+	assert.Equal(t, "invalid_client", re.ErrorCode)
+}
+
+func TestWithOAuth2RetryUnauthorized(t *testing.T) {
+	var issued int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != "client" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fmt.Sprintf(`{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, n)))
+	}))
+	defer tokenServer.Close()
+
+	// The first token is revoked by the API server.
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"authorization":"` + r.Header.Get("Authorization") + `"}`))
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.NewClient().
+		WithTimeout(2).
+		WithOAuth2(&oauth2.Config{
+			TokenURL:     tokenServer.URL,
+			ClientID:     "client",
+			ClientSecret: "secret",
+			Scopes:       []string{"read", "write"},
+		}).
+		R().
+		Get(ts.URL, nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"authorization":"Bearer token-2"}`, string(resp.Body))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&issued))
+}
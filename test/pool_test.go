@@ -0,0 +1,82 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+// waitStats to wait until the stats of the host address is expected.
+func waitStats(client *panggilhttp.Client, addr string, expected panggilhttp.HostStats) panggilhttp.HostStats {
+	var stats panggilhttp.HostStats
+	for i := 0; i < 200; i++ {
+		stats = client.Stats()[addr]
+		if stats == expected {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return stats
+}
+
+func TestWithPool(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	addr := ts.Listener.Addr().String()
+	client := panggilhttp.NewClient().
+		WithTimeout(5).
+		WithPool(&panggilhttp.PoolConfig{
+			MaxConnsPerHost:     1,
+			MaxConnWaitTimeout:  5 * time.Second,
+			MaxIdleConnDuration: 500 * time.Millisecond,
+			ReadTimeout:         5 * time.Second,
+			WriteTimeout:        5 * time.Second,
+			DialTimeout:         time.Second,
+		})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, e := client.R().Get(ts.URL, nil, nil).Do()
+			assert.NoError(t, e)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}()
+	}
+
+	assert.Equal(t, panggilhttp.HostStats{Open: 1, Active: 1}, waitStats(client, addr, panggilhttp.HostStats{Open: 1, Active: 1}))
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, panggilhttp.HostStats{Open: 1, Idle: 1}, client.Stats()[addr])
+
+	// The idle connection is closed after MaxIdleConnDuration.
+	assert.Equal(t, panggilhttp.HostStats{}, waitStats(client, addr, panggilhttp.HostStats{}))
+	assert.Len(t, client.Stats(), 0)
+}
+
+func TestWithInvalidPool(t *testing.T) {
+	_, e := panggilhttp.NewClient().
+		WithPool(&panggilhttp.PoolConfig{MaxConnsPerHost: -1}).
+		R().
+		Get("http://localhost", nil, nil).
+		Do()
+
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidPool))
+}
@@ -0,0 +1,404 @@
+package test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProxy is a proxy which connects every target to the backend,
+// and records the requested targets.
+type fakeProxy struct {
+	ln      net.Listener
+	backend string
+
+	mu      sync.Mutex
+	targets []string
+}
+
+func newFakeProxy(t *testing.T, backend string, serve func(p *fakeProxy, conn net.Conn)) *fakeProxy {
+	ln, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return startFakeProxy(ln, backend, serve)
+}
+
+// startFakeProxy to serve the proxy with the listener, like a TLS listener for an https proxy.
+func startFakeProxy(ln net.Listener, backend string, serve func(p *fakeProxy, conn net.Conn)) *fakeProxy {
+	p := &fakeProxy{ln: ln, backend: backend}
+	go func() {
+		for {
+			conn, e := ln.Accept()
+			if e != nil {
+				return
+			}
+
+			go serve(p, conn)
+		}
+	}()
+
+	return p
+}
+
+func (p *fakeProxy) addr() string {
+	return p.ln.Addr().String()
+}
+
+func (p *fakeProxy) record(target string) {
+	p.mu.Lock()
+	p.targets = append(p.targets, target)
+	p.mu.Unlock()
+}
+
+func (p *fakeProxy) recorded() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.targets...)
+}
+
+func (p *fakeProxy) pipe(conn net.Conn, r io.Reader) {
+	backend, e := net.Dial("tcp", p.backend)
+	if e != nil {
+		return
+	}
+	defer backend.Close()
+
+	go io.Copy(backend, r)
+	io.Copy(conn, backend)
+}
+
+func serveHTTPProxy(p *fakeProxy, conn net.Conn) {
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	for {
+		req, e := http.ReadRequest(br)
+		if e != nil {
+			return
+		}
+
+		if req.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("administrator:password")) {
+			conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n"))
+			return
+		}
+
+		if req.Method == http.MethodConnect {
+			p.record("CONNECT " + req.Host)
+			conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+			p.pipe(conn, br)
+
+			return
+		}
+
+		// The request to an HTTP target is in the absolute-form.
+		p.record(req.Method + " " + req.RequestURI)
+
+		backend, e := net.Dial("tcp", p.backend)
+		if e != nil {
+			return
+		}
+
+		req.Header.Del("Proxy-Authorization")
+		req.Write(backend)
+		resp, e := http.ReadResponse(bufio.NewReader(backend), req)
+		if e != nil {
+			backend.Close()
+			return
+		}
+		resp.Write(conn)
+		backend.Close()
+	}
+}
+
+func serveSOCKS5(p *fakeProxy, conn net.Conn) {
+	defer conn.Close()
+
+	head := make([]byte, 2)
+	if _, e := io.ReadFull(conn, head); e != nil {
+		return
+	}
+	io.ReadFull(conn, make([]byte, head[1]))
+
+	// Username and password authentication
+	conn.Write([]byte{0x05, 0x02})
+	auth := make([]byte, 2)
+	io.ReadFull(conn, auth)
+	username := make([]byte, auth[1])
+	io.ReadFull(conn, username)
+	io.ReadFull(conn, auth[:1])
+	password := make([]byte, auth[0])
+	io.ReadFull(conn, password)
+
+	if string(username) != "administrator" || string(password) != "password" {
+		conn.Write([]byte{0x01, 0x01})
+		return
+	}
+	conn.Write([]byte{0x01, 0x00})
+
+	req := make([]byte, 5)
+	io.ReadFull(conn, req)
+	if req[3] != 0x03 {
+		conn.Write([]byte{0x05, 0x08, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return
+	}
+
+	host := make([]byte, req[4])
+	io.ReadFull(conn, host)
+	port := make([]byte, 2)
+	io.ReadFull(conn, port)
+
+	p.record(net.JoinHostPort(string(host), strconv.Itoa(int(port[0])<<8|int(port[1]))))
+	conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0, 0})
+	p.pipe(conn, conn)
+}
+
+func TestWithHTTPProxy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"host":"` + r.Host + `"}`))
+	}))
+	defer ts.Close()
+
+	p := newFakeProxy(t, ts.Listener.Addr().String(), serveHTTPProxy)
+	defer p.ln.Close()
+
+	client := panggilhttp.NewClient().WithProxy("http://administrator:password@" + p.addr())
+
+	resp, e := client.R().Get("http://backend.test:8080/profile", nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"host":"backend.test:8080"}`, string(resp.Body))
+
+	resp, e = client.R().Post("http://backend.test/profile").SendJSON(map[string]interface{}{"name": "panggilhttp"}).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, `{"host":"backend.test"}`, string(resp.Body))
+
+	assert.Equal(t, []string{
+		"GET http://backend.test:8080/profile",
+		"POST http://backend.test/profile",
+	}, p.recorded())
+
+	// The HTTPS target uses a tunnel with the CONNECT method.
+	d, e := proxy.New("http://administrator:password@" + p.addr())
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	conn, e := d.DialScheme("https", "backend.test:443")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET /profile HTTP/1.1\r\nHost: backend.test\r\n\r\n"))
+	tunnelResp, e := http.ReadResponse(bufio.NewReader(conn), nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	tunnelResp.Body.Close()
+	assert.Equal(t, http.StatusOK, tunnelResp.StatusCode)
+	assert.Equal(t, "CONNECT backend.test:443", p.recorded()[2])
+
+	// Wrong proxy credentials, the HTTP target gets the response of the proxy
+	// and the HTTPS target cannot make the tunnel.
+	d.URL.User = url.UserPassword("administrator", "wrong")
+	_, e = d.DialScheme("https", "backend.test:443")
+	assert.Contains(t, e.Error(), "407")
+
+	resp, e = panggilhttp.NewClient().
+		WithProxy("http://administrator:wrong@"+p.addr()).
+		R().
+		Get("http://backend.test:8080/profile", nil, nil).
+		Do()
+	assert.NoError(t, e)
+	assert.Equal(t, http.StatusProxyAuthRequired, resp.StatusCode)
+}
+
+func TestWithSOCKS5Proxy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"host":"` + r.Host + `"}`))
+	}))
+	defer ts.Close()
+
+	p := newFakeProxy(t, ts.Listener.Addr().String(), serveSOCKS5)
+	defer p.ln.Close()
+
+	resp, e := panggilhttp.NewClient().
+		WithProxy("socks5://administrator:password@"+p.addr()).
+		R().
+		Get("http://backend.test/profile", nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, `{"host":"backend.test"}`, string(resp.Body))
+	assert.Equal(t, []string{"backend.test:80"}, p.recorded())
+}
+
+func TestWithProxyFromEnvironment(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"host":"` + r.Host + `"}`))
+	}))
+	defer ts.Close()
+
+	p := newFakeProxy(t, ts.Listener.Addr().String(), serveHTTPProxy)
+	defer p.ln.Close()
+
+	for _, name := range []string{"HTTP_PROXY", "NO_PROXY"} {
+		defer os.Setenv(name, os.Getenv(name))
+	}
+	os.Setenv("HTTP_PROXY", "administrator:password@"+p.addr())
+	os.Setenv("NO_PROXY", "internal.test")
+
+	client := panggilhttp.NewClient().WithProxyFromEnvironment()
+
+	resp, e := client.R().Get("http://backend.test/profile", nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, `{"host":"backend.test"}`, string(resp.Body))
+
+	// The loopback address is never proxied.
+	resp, e = client.R().Get(ts.URL, nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, `{"host":"`+ts.Listener.Addr().String()+`"}`, string(resp.Body))
+	assert.Equal(t, []string{"GET http://backend.test/profile"}, p.recorded())
+
+	os.Setenv("HTTP_PROXY", "ftp://proxy.test")
+	_, e = panggilhttp.NewClient().WithProxyFromEnvironment().R().Get(ts.URL, nil, nil).Do()
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidProxy))
+	assert.Contains(t, e.Error(), "unsupported proxy scheme")
+}
+
+func TestProxyEnvironment(t *testing.T) {
+	env, e := proxy.NewEnvironment("http://proxy.test:3128", "socks5://proxy.test", "example.com, .internal.test, 10.0.0.0/8, api.test:8080")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	tests := []struct {
+		scheme string
+		addr   string
+		proxy  string
+	}{
+		{"http", "backend.test:80", "http://proxy.test:3128"},
+		{"https", "backend.test:443", "socks5://proxy.test"},
+		{"https", "backend.test:8443", "socks5://proxy.test"},
+		{"http", "backend.test:443", "http://proxy.test:3128"},
+		{"http", "example.com:80", ""},
+		{"https", "www.example.com:443", ""},
+		{"http", "notexample.com:80", "http://proxy.test:3128"},
+		{"http", "internal.test:80", "http://proxy.test:3128"},
+		{"http", "db.internal.test:80", ""},
+		{"http", "10.1.2.3:80", ""},
+		{"http", "api.test:8080", ""},
+		{"http", "api.test:80", "http://proxy.test:3128"},
+		{"http", "localhost:80", ""},
+		{"http", "127.0.0.1:80", ""},
+	}
+
+	for _, tt := range tests {
+		d := env.Proxy(tt.scheme, tt.addr)
+		if tt.proxy == "" {
+			assert.Nil(t, d, tt.addr)
+		} else if assert.NotNil(t, d, tt.addr) {
+			assert.Equal(t, tt.proxy, d.URL.String(), tt.addr)
+		}
+	}
+
+	env, _ = proxy.NewEnvironment("http://proxy.test:3128", "", "*")
+	assert.Nil(t, env.Proxy("http", "backend.test:80"))
+}
+
+func TestWithProxyAfterCall(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"host":"` + r.Host + `"}`))
+	}))
+	defer ts.Close()
+
+	p := newFakeProxy(t, ts.Listener.Addr().String(), serveHTTPProxy)
+	defer p.ln.Close()
+
+	client := panggilhttp.NewClient()
+	_, e := client.R().Get(ts.URL, nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// The proxy is used by the host which is already called.
+	client.WithProxy("http://administrator:password@" + p.addr())
+	_, e = client.R().Get(ts.URL, nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, []string{"GET " + ts.URL + "/"}, p.recorded())
+}
+
+func TestWithHTTPSProxy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"host":"` + r.Host + `"}`))
+	}))
+	defer ts.Close()
+
+	// The certificate of the https proxy is not trusted by the system.
+	certServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer certServer.Close()
+
+	ln, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	p := startFakeProxy(tls.NewListener(ln, certServer.TLS), ts.Listener.Addr().String(), serveHTTPProxy)
+	defer p.ln.Close()
+
+	_, e = panggilhttp.NewClient().
+		WithProxy("https://administrator:password@"+p.addr()).
+		R().
+		Get("http://backend.test/profile", nil, nil).
+		Do()
+	assert.Error(t, e)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certServer.Certificate())
+
+	resp, e := panggilhttp.NewClient().
+		WithRootCAs(pool).
+		WithMinTLSVersion(tls.VersionTLS12).
+		WithProxy("https://administrator:password@"+p.addr()).
+		R().
+		Get("http://backend.test/profile", nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, `{"host":"backend.test"}`, string(resp.Body))
+	assert.Equal(t, []string{"GET http://backend.test/profile"}, p.recorded())
+}
@@ -0,0 +1,152 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/ratelimit"
+	"github.com/KodepandaID/panggilhttp/pkg/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	l := ratelimit.New(&ratelimit.Config{
+		Requests: 10,
+		Interval: time.Second,
+		Burst:    2,
+	})
+
+	assert.True(t, l.Allow())
+	assert.True(t, l.Allow())
+	assert.False(t, l.Allow())
+
+	time.Sleep(110 * time.Millisecond)
+	assert.True(t, l.Allow())
+}
+
+func TestLimiterWait(t *testing.T) {
+	l := ratelimit.New(&ratelimit.Config{
+		Requests: 10,
+		Interval: time.Second,
+		Burst:    1,
+	})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, l.Wait(context.Background()))
+	}
+
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, int64(elapsed), int64(190*time.Millisecond))
+	assert.Less(t, int64(elapsed), int64(400*time.Millisecond))
+}
+
+func TestLimiterWaitCanceled(t *testing.T) {
+	l := ratelimit.New(&ratelimit.Config{
+		Requests: 1,
+		Interval: time.Minute,
+	})
+	assert.True(t, l.Allow())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	assert.Equal(t, context.Canceled, l.Wait(ctx))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, ratelimit.ErrLimited, l.Wait(ctx))
+}
+
+func TestLimiterPerHost(t *testing.T) {
+	g := ratelimit.NewGroup(&ratelimit.Config{
+		Requests: 1,
+		Interval: time.Minute,
+		PerHost:  true,
+		FailFast: true,
+	})
+
+	assert.NoError(t, g.Take(context.Background(), "a.example.com"))
+	assert.NoError(t, g.Take(context.Background(), "b.example.com"))
+	assert.Equal(t, ratelimit.ErrLimited, g.Take(context.Background(), "a.example.com"))
+}
+
+func TestWithRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message":"ping"}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().
+		WithRateLimit(&ratelimit.Config{
+			Requests: 1,
+			Interval: time.Minute,
+			FailFast: true,
+		})
+
+	_, e := client.R().Get(ts.URL, nil, nil).Do()
+	assert.NoError(t, e)
+
+	_, e = client.R().Get(ts.URL, nil, nil).Do()
+
+	var re *panggilhttp.RateLimitError
+	assert.True(t, errors.As(e, &re))
+	assert.True(t, errors.Is(e, panggilhttp.ErrRateLimited))
+}
+
+func TestWithRateLimitRetries(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().
+		WithRateLimit(&ratelimit.Config{
+			Requests: 2,
+			Interval: time.Minute,
+			FailFast: true,
+		}).
+		WithRetryPolicy(retry.Policy{
+			Attempts:    5,
+			Backoff:     retry.Constant{Interval: time.Millisecond},
+			StatusCodes: retry.DefaultStatusCodes,
+		})
+
+	// Every retry takes a token, so the third attempt is limited.
+	_, e := client.R().Get(ts.URL, nil, nil).Do()
+	assert.True(t, errors.Is(e, panggilhttp.ErrRateLimited))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestWithRateLimitWait(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message":"ping"}`))
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().
+		WithRateLimit(&ratelimit.Config{
+			Requests: 1,
+			Interval: time.Minute,
+		})
+
+	_, e := client.R().Get(ts.URL, nil, nil).Do()
+	assert.NoError(t, e)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, e = client.R().Get(ts.URL, nil, nil).DoContext(ctx)
+
+	var ce *panggilhttp.CanceledError
+	assert.True(t, errors.As(e, &ce))
+}
@@ -0,0 +1,231 @@
+package test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestWithoutRedirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.New().
+		Get(ts.URL+"/found", nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/echo", resp.Headers["Location"])
+	assert.Len(t, resp.Redirects, 0)
+}
+
+func TestWithRedirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/found":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/see-other":
+			http.Redirect(w, r, "/echo", http.StatusSeeOther)
+		case "/temporary":
+			http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+		case "/permanent":
+			http.Redirect(w, r, "/temporary", http.StatusPermanentRedirect)
+		case "/echo":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write([]byte(`{"method":"` + r.Method + `","body":"` + strings.ReplaceAll(string(body), `"`, `'`) + `"}`))
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		path   string
+		method string
+		body   string
+	}{
+		{"/found", http.MethodGet, ""},
+		{"/see-other", http.MethodGet, ""},
+		{"/temporary", http.MethodPost, "{'username':'administrator'}"},
+		{"/permanent", http.MethodPost, "{'username':'administrator'}"},
+	}
+
+	for _, tt := range tests {
+		resp, e := panggilhttp.New().
+			Post(ts.URL + tt.path).
+			SendJSON(map[string]interface{}{
+				"username": "administrator",
+			}).
+			WithRedirects(5).
+			Do()
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, tt.path)
+		assert.Equal(t, `{"method":"`+tt.method+`","body":"`+tt.body+`"}`, string(resp.Body), tt.path)
+		assert.Equal(t, ts.URL+tt.path, resp.Redirects[0].URL)
+		assert.Equal(t, http.MethodPost, resp.Redirects[0].Method)
+	}
+
+	resp, e := panggilhttp.New().
+		Put(ts.URL + "/permanent").
+		WithRedirects(5).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, []panggilhttp.Redirect{
+		{URL: ts.URL + "/permanent", Method: http.MethodPut, StatusCode: http.StatusPermanentRedirect, Location: ts.URL + "/temporary"},
+		{URL: ts.URL + "/temporary", Method: http.MethodPut, StatusCode: http.StatusTemporaryRedirect, Location: ts.URL + "/echo"},
+	}, resp.Redirects)
+}
+
+func TestTooManyRedirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	_, e := panggilhttp.New().
+		Get(ts.URL+"/loop", nil, nil).
+		WithRedirects(3).
+		Do()
+
+	var redirectErr *panggilhttp.RedirectError
+	if assert.True(t, errors.As(e, &redirectErr)) {
+		assert.True(t, errors.Is(e, panggilhttp.ErrTooManyRedirects))
+		assert.Len(t, redirectErr.Redirects, 4)
+	}
+
+	_, e = panggilhttp.New().WithRedirects(-1).Get(ts.URL, nil, nil).Do()
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidRedirects))
+}
+
+func TestRedirectErrorNotRetried(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().
+		WithRedirects(2).
+		WithRetryPolicy(retry.Policy{
+			Attempts: 3,
+			Backoff:  retry.Constant{Interval: time.Millisecond},
+		})
+
+	// The redirect loop is not retried, so the host is called once for every hop.
+	_, e := client.R().Get(ts.URL+"/loop", nil, nil).Do()
+	assert.True(t, errors.Is(e, panggilhttp.ErrTooManyRedirects))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	errBlocked := errors.New("blocked")
+	_, e = client.R().
+		Get(ts.URL+"/loop", nil, nil).
+		WithCheckRedirect(func(req *fasthttp.Request, via []panggilhttp.Redirect) error {
+			return errBlocked
+		}).
+		Do()
+	assert.True(t, errors.Is(e, errBlocked))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestCheckRedirect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/found":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/temporary":
+			http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+		case "/permanent":
+			http.Redirect(w, r, "/temporary", http.StatusPermanentRedirect)
+		case "/echo":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write([]byte(`{"method":"` + r.Method + `","body":"` + strings.ReplaceAll(string(body), `"`, `'`) + `"}`))
+		}
+	}))
+	defer ts.Close()
+
+	client := panggilhttp.NewClient().
+		WithRedirects(5).
+		WithCheckRedirect(func(req *fasthttp.Request, via []panggilhttp.Redirect) error {
+			if len(via) > 1 {
+				return panggilhttp.ErrUseLastResponse
+			}
+
+			return nil
+		})
+
+	resp, e := client.R().Get(ts.URL+"/permanent", nil, nil).Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	assert.Len(t, resp.Redirects, 1)
+
+	errBlocked := errors.New("blocked")
+	_, e = client.R().
+		Get(ts.URL+"/found", nil, nil).
+		WithCheckRedirect(func(req *fasthttp.Request, via []panggilhttp.Redirect) error {
+			return errBlocked
+		}).
+		Do()
+	assert.True(t, errors.Is(e, errBlocked))
+}
+
+func TestRedirectToAnotherHost(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"authorization":"` + r.Header.Get("Authorization") + `","cookie":"` + r.Header.Get("Cookie") + `","trace":"` + r.Header.Get("X-Trace-Id") + `"}`))
+	}))
+	defer other.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer 123456" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/same-host":
+			http.Redirect(w, r, "/other-host", http.StatusFound)
+		default:
+			// The same IP address with another hostname.
+			http.Redirect(w, r, strings.Replace(other.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.New().
+		Get(ts.URL+"/same-host", nil, nil).
+		WithBearerToken("123456").
+		WithCookie(map[string]string{"session": "123456"}).
+		WithHeader(map[string]string{"X-Trace-Id": "abc"}).
+		WithRedirects(5).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"authorization":"","cookie":"","trace":"abc"}`, string(resp.Body))
+	assert.Len(t, resp.Redirects, 2)
+}
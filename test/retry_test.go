@@ -0,0 +1,270 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestBackoffConstant(t *testing.T) {
+	b := retry.Constant{Interval: 100 * time.Millisecond}
+
+	assert.Equal(t, 100*time.Millisecond, b.Next(1, 0))
+	assert.Equal(t, 100*time.Millisecond, b.Next(5, 100*time.Millisecond))
+}
+
+func TestBackoffExponential(t *testing.T) {
+	b := retry.Exponential{Base: 100 * time.Millisecond, Max: time.Second}
+
+	assert.Equal(t, 100*time.Millisecond, b.Next(1, 0))
+	assert.Equal(t, 200*time.Millisecond, b.Next(2, 0))
+	assert.Equal(t, 400*time.Millisecond, b.Next(3, 0))
+	assert.Equal(t, time.Second, b.Next(10, 0))
+
+	b = retry.Exponential{Base: 100 * time.Millisecond, Multiplier: 3}
+	assert.Equal(t, 900*time.Millisecond, b.Next(3, 0))
+}
+
+func TestBackoffFullJitter(t *testing.T) {
+	b := retry.FullJitter{Base: 100 * time.Millisecond, Max: time.Second}
+
+	for attempt := 1; attempt < 10; attempt++ {
+		d := b.Next(attempt, 0)
+		assert.GreaterOrEqual(t, int64(d), int64(0))
+		assert.LessOrEqual(t, int64(d), int64(time.Second))
+	}
+}
+
+func TestBackoffDecorrelatedJitter(t *testing.T) {
+	b := retry.DecorrelatedJitter{Base: 100 * time.Millisecond, Max: time.Second}
+
+	var prev time.Duration
+	for attempt := 1; attempt < 10; attempt++ {
+		d := b.Next(attempt, prev)
+		assert.GreaterOrEqual(t, int64(d), int64(100*time.Millisecond))
+		assert.LessOrEqual(t, int64(d), int64(time.Second))
+
+		prev = d
+	}
+}
+
+func TestRetryPolicyMaxElapsedTime(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := ts.URL
+	ts.Close()
+
+	start := time.Now()
+	_, e := panggilhttp.New().
+		Get(url, nil, nil).
+		WithRetryPolicy(retry.Policy{
+			Attempts:       10,
+			Backoff:        retry.Exponential{Base: 100 * time.Millisecond},
+			MaxElapsedTime: 500 * time.Millisecond,
+		}).
+		Do()
+
+	// Wait 100ms, 200ms, then the next 400ms is over the max elapsed time.
+	var ce *panggilhttp.ConnectError
+	assert.True(t, errors.As(e, &ce))
+	assert.Equal(t, 3, ce.Attempts)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+}
+
+func TestRetryOnStatusCode(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"message":"ping"}`))
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.New().
+		Get(ts.URL, nil, nil).
+		WithRetryPolicy(retry.Policy{
+			Attempts:    3,
+			Backoff:     retry.Constant{Interval: 10 * time.Millisecond},
+			StatusCodes: retry.DefaultStatusCodes,
+		}).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	assert.Equal(t, 3, resp.Sources[0].Attempts)
+}
+
+func TestRetryPolicyNoAttempts(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	// An explicit policy with 0 attempts is not retried.
+	resp, e := panggilhttp.New().
+		Get(ts.URL, nil, nil).
+		WithRetryPolicy(retry.Policy{StatusCodes: retry.DefaultStatusCodes}).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	// The client policy with 0 attempts is not retried too.
+	atomic.StoreInt32(&attempts, 0)
+	resp, e = panggilhttp.NewClient().
+		WithRetryPolicy(retry.Policy{StatusCodes: retry.DefaultStatusCodes}).
+		R().
+		Get(ts.URL, nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, 1, resp.Sources[0].Attempts)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryOnCondition(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"pending"}`))
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.New().
+		Get(ts.URL, nil, nil).
+		WithRetryPolicy(retry.Policy{
+			Attempts: 2,
+			Backoff:  retry.Constant{Interval: 10 * time.Millisecond},
+			Condition: func(resp *fasthttp.Response) bool {
+				return strings.Contains(string(resp.Body()), "pending")
+			},
+		}).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// The last response is returned if the attempts are over.
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryAfter(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&attempts, 1) {
+		case 1:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.Header().Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	start := time.Now()
+	resp, e := panggilhttp.New().
+		Get(ts.URL, nil, nil).
+		WithRetryPolicy(retry.Policy{
+			Attempts:      2,
+			Backoff:       retry.Constant{Interval: 10 * time.Millisecond},
+			StatusCodes:   []int{http.StatusTooManyRequests},
+			MaxRetryAfter: 200 * time.Millisecond,
+		}).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Both Retry-After values are capped by MaxRetryAfter.
+	elapsed := time.Since(start)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.GreaterOrEqual(t, int64(elapsed), int64(400*time.Millisecond))
+	assert.Less(t, int64(elapsed), int64(time.Second))
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.New().
+		Post(ts.URL).
+		WithRetryPolicy(retry.Policy{
+			Attempts:    3,
+			Backoff:     retry.Constant{Interval: 10 * time.Millisecond},
+			StatusCodes: retry.DefaultStatusCodes,
+		}).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryWithIdempotencyKey(t *testing.T) {
+	var keys []string
+	var mu sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	_, e := panggilhttp.New().
+		Post(ts.URL).
+		SendJSON(map[string]interface{}{
+			"amount": 100,
+		}).
+		WithRetryPolicy(retry.Policy{
+			Attempts:    2,
+			Backoff:     retry.Constant{Interval: 10 * time.Millisecond},
+			StatusCodes: retry.DefaultStatusCodes,
+		}).
+		WithIdempotencyKey().
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, 3, len(keys))
+	assert.Equal(t, 36, len(keys[0]))
+	assert.Equal(t, keys[0], keys[1])
+	assert.Equal(t, keys[0], keys[2])
+}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestSignHMACSHA256(t *testing.T) {
+	key := signature.HMACSHA256([]byte("shared-secret"))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if e := signature.VerifyRequest(r, body, "sig1", key); e != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"` + e.Error() + `"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.New().
+		Post(ts.URL + "/payments?currency=IDR").
+		SendJSON(map[string]interface{}{
+			"amount": 100,
+		}).
+		WithBearerToken("123456").
+		WithSigner(signature.New(&signature.Config{
+			KeyID:      "partner-key",
+			Key:        key,
+			Components: []string{"@method", "@authority", "@path", "@query", "authorization", "content-digest", "content-type"},
+			Expires:    time.Minute,
+		})).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(resp.Body))
+}
+
+func TestSignEd25519FormData(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if e := signature.VerifyRequest(r, body, "sig1", signature.Ed25519Public(public)); e != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"` + e.Error() + `"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	resp, e := panggilhttp.NewClient().
+		WithSigner(signature.New(&signature.Config{
+			KeyID: "partner-key",
+			Key:   signature.Ed25519(private),
+		})).
+		R().
+		Post(ts.URL).
+		SendFormData(map[string]string{
+			"username": "administrator",
+		}).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(resp.Body))
+}
+
+func TestVerifySignature(t *testing.T) {
+	key := signature.HMACSHA256([]byte("shared-secret"))
+	signer := signature.New(&signature.Config{Key: key})
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	req.SetRequestURI("https://example.com:443/foo?bar=baz")
+	req.Header.SetMethod(http.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.SetBodyString(`{"hello": "world"}`)
+
+	assert.NoError(t, signer.Sign(req))
+	assert.Equal(t, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", string(req.Header.Peek("Content-Digest")))
+	assert.NoError(t, signature.Verify(req, "sig1", key))
+
+	// Wrong key
+	assert.Equal(t, signature.ErrInvalidSignature, signature.Verify(req, "sig1", signature.HMACSHA256([]byte("wrong"))))
+
+	// Wrong label
+	assert.Equal(t, signature.ErrNoSignature, signature.Verify(req, "sig2", key))
+
+	// The body is changed after signing.
+	req.SetBodyString(`{"hello": "panggilhttp"}`)
+	assert.Equal(t, signature.ErrDigestMismatch, signature.Verify(req, "sig1", key))
+}
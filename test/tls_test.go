@@ -0,0 +1,199 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KodepandaID/panggilhttp"
+	"github.com/KodepandaID/panggilhttp/pkg/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+// newClientCertificate to create a self-signed client certificate in PEM.
+func newClientCertificate(t *testing.T) (*x509.Certificate, []byte, []byte) {
+	key, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "panggilhttp"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, e := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	return cert,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestWithRootCAs(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"secure":true}`))
+	}))
+	defer ts.Close()
+
+	// The test certificate is not trusted by the system.
+	_, e := panggilhttp.New().Get(ts.URL, nil, nil).Do()
+	var connectErr *panggilhttp.ConnectError
+	assert.True(t, errors.As(e, &connectErr))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	resp, e := panggilhttp.NewClient().
+		WithRootCAs(pool).
+		WithMinTLSVersion(tls.VersionTLS12).
+		R().
+		Get(ts.URL, nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, `{"secure":true}`, string(resp.Body))
+
+	_, e = panggilhttp.NewClient().WithMinTLSVersion(0x0200).R().Get(ts.URL, nil, nil).Do()
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidTLSVersion))
+}
+
+func TestWithClientCertificate(t *testing.T) {
+	clientCert, certPEM, keyPEM := newClientCertificate(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"client":"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+	}))
+	ts.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	resp, e := panggilhttp.NewClient().
+		WithRootCAs(pool).
+		WithClientCertificate(certPEM, keyPEM).
+		R().
+		Get(ts.URL, nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	assert.Equal(t, `{"client":"panggilhttp"}`, string(resp.Body))
+
+	// Without the client certificate
+	_, e = panggilhttp.NewClient().WithRootCAs(pool).R().Get(ts.URL, nil, nil).Do()
+	assert.Error(t, e)
+
+	_, e = panggilhttp.NewClient().WithClientCertificate(certPEM, []byte("invalid")).R().Get(ts.URL, nil, nil).Do()
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidCertificate))
+}
+
+func TestWithPinnedPublicKeys(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"secure":true}`))
+	}))
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	sum := sha256.Sum256(ts.Certificate().RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(sum[:])
+
+	resp, e := panggilhttp.NewClient().
+		WithRootCAs(pool).
+		WithPinnedPublicKeys("127.0.0.1", "sha256/"+pin).
+		R().
+		Get(ts.URL, nil, nil).
+		Do()
+	if e != nil {
+		t.Fatal(e)
+	}
+	assert.Equal(t, `{"secure":true}`, string(resp.Body))
+
+	other := sha256.Sum256([]byte("other"))
+	_, e = panggilhttp.NewClient().
+		WithRootCAs(pool).
+		WithPinnedPublicKeys("127.0.0.1", base64.StdEncoding.EncodeToString(other[:])).
+		R().
+		Get(ts.URL, nil, nil).
+		Do()
+
+	var pinErr *panggilhttp.PinError
+	if assert.True(t, errors.As(e, &pinErr), e) {
+		assert.True(t, errors.Is(e, panggilhttp.ErrPinMismatch))
+		assert.Equal(t, "127.0.0.1", pinErr.Host)
+		assert.Equal(t, []string{pin}, pinErr.Pins)
+	}
+
+	_, e = panggilhttp.NewClient().WithPinnedPublicKeys("127.0.0.1", "invalid").R().Get(ts.URL, nil, nil).Do()
+	assert.True(t, errors.Is(e, panggilhttp.ErrInvalidPin))
+}
+
+func TestPinMismatchNotRetried(t *testing.T) {
+	var conns int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"secure":true}`))
+	}))
+	ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	other := sha256.Sum256([]byte("other"))
+	_, e := panggilhttp.NewClient().
+		WithRootCAs(pool).
+		WithPinnedPublicKeys("127.0.0.1", base64.StdEncoding.EncodeToString(other[:])).
+		WithRetryPolicy(retry.Policy{
+			Attempts: 2,
+			Backoff:  retry.Constant{Interval: time.Millisecond},
+		}).
+		R().
+		Get(ts.URL, nil, nil).
+		Do()
+
+	assert.True(t, errors.Is(e, panggilhttp.ErrPinMismatch))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
@@ -0,0 +1,214 @@
+package panggilhttp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// WithRootCAs to verify the server certificates with the root certificate authorities,
+// instead of the system root certificate authorities.
+func (cl *Client) WithRootCAs(pool *x509.CertPool) *Client {
+	cl.tlsConfig().RootCAs = pool
+
+	return cl
+}
+
+// WithClientCertificate to send the client certificate for the mutual TLS authentication,
+// certPEM and keyPEM are the PEM encoded certificate chain and private key.
+func (cl *Client) WithClientCertificate(certPEM, keyPEM []byte) *Client {
+	cert, e := tls.X509KeyPair(certPEM, keyPEM)
+	if e != nil {
+		cl.errs = append(cl.errs, fmt.Errorf("%w: %s", ErrInvalidCertificate, e))
+
+		return cl
+	}
+
+	cfg := cl.tlsConfig()
+	cfg.Certificates = append(cfg.Certificates, cert)
+
+	return cl
+}
+
+// WithMinTLSVersion to set the minimum TLS version, like tls.VersionTLS12.
+func (cl *Client) WithMinTLSVersion(version uint16) *Client {
+	if version < tls.VersionTLS10 || version > tls.VersionTLS13 {
+		cl.errs = append(cl.errs, ErrInvalidTLSVersion)
+
+		return cl
+	}
+
+	cl.tlsConfig().MinVersion = version
+
+	return cl
+}
+
+// WithPinnedPublicKeys to pin the public keys of the host, the host name or IP address without the port.
+// A pin is the base64 encoded SHA-256 hash of the Subject Public Key Info,
+// with or without the "sha256/" prefix. The connection is made only if one of the
+// server certificates matches one of the pins, otherwise a *PinError is returned.
+func (cl *Client) WithPinnedPublicKeys(host string, pins ...string) *Client {
+	if len(pins) == 0 {
+		cl.errs = append(cl.errs, ErrInvalidPin)
+
+		return cl
+	}
+
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+
+	for _, pin := range pins {
+		pin = strings.TrimPrefix(pin, "sha256/")
+		if b, e := base64.StdEncoding.DecodeString(pin); e != nil || len(b) != sha256.Size {
+			cl.errs = append(cl.errs, fmt.Errorf("%w: %s", ErrInvalidPin, pin))
+
+			return cl
+		}
+
+		cl.pins[host] = append(cl.pins[host], pin)
+	}
+
+	cl.tlsConfig().VerifyConnection = cl.verifyPins
+
+	return cl
+}
+
+// tlsConfig to get the TLS configuration of the client to change it, it is created if not exists.
+// The clients of the schemes are created again with the changed configuration.
+func (cl *Client) tlsConfig() *tls.Config {
+	if cl.hc.TLSConfig == nil {
+		cl.hc.TLSConfig = &tls.Config{}
+	}
+	cl.resetClients()
+
+	if cl.pins == nil {
+		cl.pins = make(map[string][]string)
+	}
+
+	return cl.hc.TLSConfig
+}
+
+// handshake to make the TLS connection to the host address.
+// The handshake is made while dialing, so a failed handshake like a pin mismatch
+// fails the connection instead of the first request, which fasthttp retries.
+func (cl *Client) handshake(conn net.Conn, addr string) (net.Conn, error) {
+	timeout := cl.dialTimeout
+	if timeout == 0 {
+		timeout = fasthttp.DefaultDialTimeout
+	}
+
+	tlsConn := tls.Client(conn, cl.serverTLSConfig(addr))
+	conn.SetDeadline(time.Now().Add(timeout))
+	if e := tlsConn.Handshake(); e != nil {
+		conn.Close()
+
+		return nil, e
+	}
+	conn.SetDeadline(time.Time{})
+
+	return tlsConn, nil
+}
+
+// serverTLSConfig to get the TLS configuration of the host address,
+// with the server name and a session cache for every host address.
+func (cl *Client) serverTLSConfig(addr string) *tls.Config {
+	cl.clientsMu.Lock()
+	defer cl.clientsMu.Unlock()
+
+	if cfg, ok := cl.tlsConfigs[addr]; ok {
+		return cfg
+	}
+
+	cfg := &tls.Config{}
+	if cl.hc.TLSConfig != nil {
+		cfg = cl.hc.TLSConfig.Clone()
+	}
+
+	if cfg.ClientSessionCache == nil {
+		cfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	if cfg.ServerName == "" {
+		cfg.ServerName = addr
+		if host, _, e := net.SplitHostPort(addr); e == nil {
+			cfg.ServerName = host
+		}
+	}
+	cl.tlsConfigs[addr] = cfg
+
+	return cfg
+}
+
+// verifyPins to check the server certificates with the pinned public keys of the host.
+// The SNI is not sent for an IP address, so the IP addresses of the verified
+// leaf certificate are checked instead, the dialed IP address is one of them.
+func (cl *Client) verifyPins(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+
+	hosts := []string{strings.ToLower(cs.ServerName)}
+	if cs.ServerName == "" {
+		hosts = hosts[:0]
+		for _, ip := range cs.PeerCertificates[0].IPAddresses {
+			hosts = append(hosts, ip.String())
+		}
+	}
+
+	got := make([]string, 0, len(cs.PeerCertificates))
+	for _, cert := range cs.PeerCertificates {
+		got = append(got, publicKeyPin(cert))
+	}
+
+	for _, host := range hosts {
+		pins, ok := cl.pins[host]
+		if ok && !matchPin(pins, got) {
+			return &pinMismatch{host: host, pins: got}
+		}
+	}
+
+	return nil
+}
+
+func matchPin(pins, got []string) bool {
+	for _, pin := range pins {
+		for _, g := range got {
+			if pin == g {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// publicKeyPin to get the SPKI pin of the certificate.
+func publicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// pinMismatch is the TLS handshake error of a pin mismatch, it becomes a *PinError.
+type pinMismatch struct {
+	host string
+	pins []string
+}
+
+func (e *pinMismatch) Error() string {
+	return fmt.Sprintf("%s for %s", ErrPinMismatch, e.host)
+}
+
+// Permanent to stop the HTTP retry, the server certificates are the same in every attempt.
+func (e *pinMismatch) Permanent() bool {
+	return true
+}